@@ -36,6 +36,20 @@ func assertErrorIs(t *testing.T, expected error, value error, msgAndArgs ...any)
 	return true
 }
 
+func assertPanics(t *testing.T, f func(), msgAndArgs ...any) bool {
+	t.Helper()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("did not panic: %s", messageFromMsgAndArgs(msgAndArgs...))
+		}
+	}()
+
+	f()
+
+	return true
+}
+
 func messageFromMsgAndArgs(msgAndArgs ...interface{}) string {
 	if len(msgAndArgs) == 0 {
 		return ""
@@ -0,0 +1,157 @@
+package turborpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes of calls that are not authenticated or not authorized.
+const (
+	CodeUnauthenticated = "unauthenticated"
+	CodeForbidden       = "forbidden"
+)
+
+var (
+	// ErrUnauthenticated is answered with status code 401. Policies return
+	// it, or an error wrapping it, for calls that need a principal.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is answered with status code 403. Errors returned by
+	// policies that do not wrap ErrUnauthenticated are wrapped in it.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// An Authenticator returns the principal making a request, e.g. the user of
+// a session cookie or a bearer token. It returns a nil principal for
+// anonymous requests, and an error for requests with invalid credentials,
+// which are answered with status code 401 and the message of
+// ErrUnauthenticated. Errors that are an RPCError or an HTTPStatusError are
+// sent to the client as they are returned by methods.
+type Authenticator func(r *http.Request) (principal any, err error)
+
+// A Policy authorizes a method call made by principal, which is nil if the
+// call is anonymous. Calls are only made if every policy of the method
+// returns nil, see WithMethodPolicy.
+type Policy func(ctx context.Context, principal any) error
+
+// A RoleHolder is a principal with roles, see RequireRole.
+type RoleHolder interface {
+	HasRole(role string) bool
+}
+
+// principalKey is the context key of the principal making a call.
+type principalKey struct{}
+
+// WithAuthenticator sets the authenticator of the server. Every request is
+// authenticated before any method is called, WebSocket connections when
+// they are opened and batches as a whole. The principal is passed to the
+// policies of the called method and is available to the method with
+// PrincipalFromContext.
+func WithAuthenticator(authenticate Authenticator) ServerOption {
+	return func(r *Server) {
+		r.authenticator = authenticate
+	}
+}
+
+// WithServicePolicy adds a policy to every method of the service. Service
+// policies are checked before method policies.
+func WithServicePolicy(policy Policy) ServiceOption {
+	return func(s *service) {
+		s.policies = append(s.policies, policy)
+	}
+}
+
+// WithMethodPolicy adds a policy to the method. Policies are checked in the
+// order they are added, before the call's rate and concurrency limits and
+// before its input is decoded.
+func WithMethodPolicy(policy Policy) MethodOption {
+	return func(m *method) {
+		m.policies = append(m.policies, policy)
+	}
+}
+
+// PrincipalFromContext returns the principal making a method call, or nil if
+// the call is anonymous or the server has no authenticator.
+func PrincipalFromContext(ctx context.Context) any {
+	return ctx.Value(principalKey{})
+}
+
+// Authenticated is a policy that only allows calls with a principal.
+func Authenticated() Policy {
+	return func(ctx context.Context, principal any) error {
+		if principal == nil {
+			return ErrUnauthenticated
+		}
+
+		return nil
+	}
+}
+
+// RequireRole is a policy that only allows calls by a principal that
+// implements RoleHolder and has at least one of the roles.
+func RequireRole(roles ...string) Policy {
+	return func(ctx context.Context, principal any) error {
+		if principal == nil {
+			return ErrUnauthenticated
+		}
+
+		if holder, ok := principal.(RoleHolder); ok {
+			for _, role := range roles {
+				if holder.HasRole(role) {
+					return nil
+				}
+			}
+		}
+
+		return fmt.Errorf("%w: requires role %s", ErrForbidden, strings.Join(roles, " or "))
+	}
+}
+
+// authenticate returns the request with the principal making it in its
+// context. If the request cannot be authenticated it is answered with an
+// error, see Authenticator, and false is returned.
+func (rpc *Server) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if rpc.authenticator == nil {
+		return r, true
+	}
+
+	principal, err := rpc.authenticator(r)
+
+	if err != nil {
+		var statusErr HTTPStatusError
+
+		if errors.As(err, &statusErr) {
+			httpError(w, statusErr.HTTPStatus(), err)
+		} else {
+			// The error may describe the credentials, so it is not sent.
+			httpError(w, http.StatusUnauthorized, ErrUnauthenticated)
+		}
+
+		return r, false
+	}
+
+	if principal == nil {
+		return r, true
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)), true
+}
+
+// authorize checks the policies of a method call.
+func (m *method) authorize(ctx context.Context) error {
+	principal := PrincipalFromContext(ctx)
+
+	for _, policy := range m.policies {
+		if err := policy(ctx, principal); err != nil {
+			if errors.Is(err, ErrUnauthenticated) || errors.Is(err, ErrForbidden) {
+				return err
+			}
+
+			return fmt.Errorf("%w: %w", ErrForbidden, err)
+		}
+	}
+
+	return nil
+}
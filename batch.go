@@ -0,0 +1,127 @@
+package turborpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var (
+	errDecodingBatch = errors.New("decoding batch")
+	errBatchTooLarge = errors.New("too many calls in batch")
+)
+
+// defaultMaxBatchSize is the maximum number of calls in a batch request
+// unless it is set with WithMaxBatchSize.
+const defaultMaxBatchSize = 100
+
+// batchConcurrency is the maximum number of calls in a batch request that are
+// executed at the same time.
+const batchConcurrency = 16
+
+// WithMaxBatchSize limits the number of calls in a batch request to n, larger
+// batches are answered with status code 413. The generated clients split
+// batches larger than n into several requests. Zero or less means no limit,
+// the default limit is 100.
+func WithMaxBatchSize(n int) ServerOption {
+	return func(r *Server) {
+		r.maxBatchSize = n
+	}
+}
+
+// batchCall is a single call in a batch request.
+type batchCall struct {
+	Service string          `json:"service"`
+	Method  string          `json:"method"`
+	Input   json.RawMessage `json:"input"`
+}
+
+// serveBatch answers a batch request. The body of a batch request is a JSON
+// array of calls on the form
+//
+//	{"service": "Service", "method": "Method", "input": ...}
+//
+// The calls are executed concurrently, at most batchConcurrency at a time,
+// and the response is a JSON array with a result for each call in the same
+// order. A result is either {"output": ...} or {"status": ..., "message": ...}
+// like the response to a single call.
+func (rpc *Server) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if rpc.maxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, rpc.maxRequestBytes)
+	}
+
+	body, err := io.ReadAll(r.Body)
+
+	if isMaxBytesError(err) {
+		httpError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var calls []batchCall
+
+	if err := json.Unmarshal(body, &calls); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("%w: %w", errDecodingBatch, err))
+		return
+	}
+
+	if rpc.maxBatchSize > 0 && len(calls) > rpc.maxBatchSize {
+		httpError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("%w: %d calls, at most %d are allowed", errBatchTooLarge, len(calls), rpc.maxBatchSize))
+		return
+	}
+
+	ctx := withRequest(r.Context(), r)
+	results := make([]any, len(calls))
+	sem := make(chan struct{}, batchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, c batchCall) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			s, m, err := rpc.lookup(c.Service, c.Method)
+
+			var output any
+			if err == nil {
+				output, err = rpc.call(ctx, s, m, m.bytesInput(c.Input), JSONCodec)
+			}
+
+			var buf []byte
+			if err == nil {
+				buf, err = encodeOutput(JSONCodec, CallInfo{Service: s.name, Method: m.name}, output)
+			}
+
+			if err != nil {
+				results[i] = newErrorResponse(rpc.errorStatus(err))
+				return
+			}
+
+			results[i] = outputResponse{
+				Output: json.RawMessage(buf),
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	buf, _ := json.Marshal(results)
+	buf = rpc.compress(w, r, buf)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(buf)
+}
@@ -0,0 +1,198 @@
+package turborpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// A Client calls the methods of a server over HTTP from Go, see Call. It
+// speaks the same wire format as the generated JavaScript and TypeScript
+// clients, with the input and output encoded as JSON.
+type Client struct {
+	url               string
+	httpClient        *http.Client
+	header            http.Header
+	version           string
+	onVersionMismatch func(clientVersion, serverVersion string)
+}
+
+// NewClient returns a client of the server at url, the URL the server's
+// handler is mounted at, e.g. "https://example.com/rpc".
+func NewClient(url string, options ...ClientOption) *Client {
+	c := &Client{
+		url:        url,
+		httpClient: http.DefaultClient,
+		header:     make(http.Header),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// WithHTTPClient sets the HTTP client used to make requests, by default it
+// is http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header that is sent with every request, e.g. an
+// Authorization header.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithClientVersion sets the version of the server the client was written
+// for, see WithVersionMismatchHandler.
+func WithClientVersion(version string) ClientOption {
+	return func(c *Client) {
+		c.version = version
+	}
+}
+
+// WithVersionMismatchHandler sets a function that is called when the
+// X-Server-Version header of a response is not the version set with
+// WithClientVersion, i.e. the services of the server have changed.
+func WithVersionMismatchHandler(handler func(clientVersion, serverVersion string)) ClientOption {
+	return func(c *Client) {
+		c.onVersionMismatch = handler
+	}
+}
+
+// Call calls a method of a service on the server and returns its output. If
+// ctx has a deadline it is sent to the server with the X-Timeout-Ms header.
+// If the server answers with an error the error is an *RPCError whose
+// Details are the raw JSON details, if any.
+func Call[In, Out any](ctx context.Context, c *Client, service, method string, in In) (Out, error) {
+	var out Out
+
+	u, err := url.Parse(c.url)
+
+	if err != nil {
+		return out, err
+	}
+
+	q := u.Query()
+	q.Set("service", service)
+	q.Set("method", method)
+	u.RawQuery = q.Encode()
+
+	body, err := json.Marshal(in)
+
+	if err != nil {
+		return out, fmt.Errorf("encoding input: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+
+	if err != nil {
+		return out, err
+	}
+
+	for key, values := range c.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+
+	req.Header.Set("Content-Type", JSONCodec.ContentType())
+	req.Header.Set("Accept", JSONCodec.ContentType())
+
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := time.Until(deadline).Milliseconds()
+
+		if ms < 1 {
+			ms = 1
+		}
+
+		req.Header.Set(timeoutHeader, strconv.FormatInt(ms, 10))
+	}
+
+	res, err := c.httpClient.Do(req)
+
+	if err != nil {
+		return out, err
+	}
+
+	defer res.Body.Close()
+
+	c.checkVersion(res.Header.Get("X-Server-Version"))
+
+	buf, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return out, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return out, decodeError(res.StatusCode, buf)
+	}
+
+	resp := struct {
+		Output *Out `json:"output"`
+	}{Output: &out}
+
+	if err := json.Unmarshal(buf, &resp); err != nil {
+		return out, fmt.Errorf("decoding output: %w", err)
+	}
+
+	return out, nil
+}
+
+// checkVersion calls the version mismatch handler if the server's version is
+// not the client's.
+func (c *Client) checkVersion(serverVersion string) {
+	if c.onVersionMismatch != nil && c.version != "" && serverVersion != "" && c.version != serverVersion {
+		c.onVersionMismatch(c.version, serverVersion)
+	}
+}
+
+// decodeError decodes an error response. Responses that are not an error
+// response, e.g. from a proxy, are turned into an RPCError with the default
+// error code of their status code.
+func decodeError(status int, buf []byte) error {
+	var resp struct {
+		Status  int             `json:"status"`
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Details json.RawMessage `json:"details"`
+	}
+
+	if err := json.Unmarshal(buf, &resp); err != nil || resp.Code == "" {
+		return &RPCError{
+			Code:    errorCode(status),
+			Status:  status,
+			Message: http.StatusText(status),
+		}
+	}
+
+	rpcErr := &RPCError{
+		Code:    resp.Code,
+		Status:  resp.Status,
+		Message: resp.Message,
+	}
+
+	if resp.Status == 0 {
+		rpcErr.Status = status
+	}
+
+	if len(resp.Details) > 0 {
+		rpcErr.Details = resp.Details
+	}
+
+	return rpcErr
+}
@@ -0,0 +1,143 @@
+package turborpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	rpc := newTestServer()
+	rpc.MustRegister(&TestService1{})
+	rpc.MustRegister(&TestServiceCoded{})
+	rpc.MustRegister(&TestServiceShipment{})
+	rpc.MustRegister(&TestServiceSlow{})
+
+	ts := httptest.NewServer(rpc)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	t.Run("call", func(t *testing.T) {
+		c := NewClient(ts.URL)
+
+		n, err := Call[int, int](ctx, c, "TestService1", "Three", 2)
+		assertNoError(t, err)
+		assertEqual(t, 3, n)
+
+		_, err = Call[any, struct{}](ctx, c, "TestService1", "One", nil)
+		assertNoError(t, err)
+
+		shipment, err := Call[TestItem, TestShipment](ctx, c, "TestServiceShipment", "Ship", TestItem{Name: "book"})
+		assertNoError(t, err)
+		assertEqual(t, "book", shipment.Name)
+		assertEqual(t, true, time.Time(shipment.Shipped).Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := NewClient(ts.URL)
+
+		_, err := Call[any, any](ctx, c, "TestServiceCoded", "OutOfStock", nil)
+
+		var rpcErr *RPCError
+		assertEqual(t, true, errors.As(err, &rpcErr))
+		assertEqual(t, "out_of_stock", rpcErr.Code)
+		assertEqual(t, http.StatusConflict, rpcErr.Status)
+		assertEqual(t, "out of stock", rpcErr.Message)
+
+		var details TestStockDetails
+		assertNoError(t, json.Unmarshal(rpcErr.Details.(json.RawMessage), &details))
+		assertEqual(t, 2, details.Available)
+
+		_, err = Call[any, any](ctx, c, "TestService1", "Missing", nil)
+		assertEqual(t, true, errors.As(err, &rpcErr))
+		assertEqual(t, CodeNotFound, rpcErr.Code)
+		assertEqual(t, nil, rpcErr.Details)
+	})
+
+	t.Run("not an error response", func(t *testing.T) {
+		c := NewClient(ts.URL + "/missing")
+		c.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			w := httptest.NewRecorder()
+			http.NotFound(w, r)
+			return w.Result(), nil
+		})}
+
+		_, err := Call[any, any](ctx, c, "TestService1", "One", nil)
+
+		var rpcErr *RPCError
+		assertEqual(t, true, errors.As(err, &rpcErr))
+		assertEqual(t, CodeNotFound, rpcErr.Code)
+		assertEqual(t, http.StatusNotFound, rpcErr.Status)
+	})
+
+	t.Run("version", func(t *testing.T) {
+		var got [2]string
+
+		c := NewClient(ts.URL, WithClientVersion("old"), WithVersionMismatchHandler(func(clientVersion, serverVersion string) {
+			got = [2]string{clientVersion, serverVersion}
+		}))
+
+		_, err := Call[any, struct{}](ctx, c, "TestService1", "One", nil)
+		assertNoError(t, err)
+		assertEqual(t, [2]string{"old", rpc.version}, got)
+
+		got = [2]string{}
+
+		c = NewClient(ts.URL, WithClientVersion(rpc.version), WithVersionMismatchHandler(func(clientVersion, serverVersion string) {
+			got = [2]string{clientVersion, serverVersion}
+		}))
+
+		_, err = Call[any, struct{}](ctx, c, "TestService1", "One", nil)
+		assertNoError(t, err)
+		assertEqual(t, [2]string{}, got)
+	})
+
+	t.Run("http client and headers", func(t *testing.T) {
+		var header http.Header
+
+		httpClient := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			header = r.Header
+			return http.DefaultTransport.RoundTrip(r)
+		})}
+
+		c := NewClient(ts.URL+"?key=value", WithHTTPClient(httpClient), WithHeader("Authorization", "Bearer token"))
+
+		n, err := Call[int, int](ctx, c, "TestService1", "Three", 1)
+		assertNoError(t, err)
+		assertEqual(t, 3, n)
+		assertEqual(t, "Bearer token", header.Get("Authorization"))
+		assertEqual(t, "application/json", header.Get("Content-Type"))
+		assertEqual(t, "", header.Get(timeoutHeader))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		var timeout string
+
+		c := NewClient(ts.URL, WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			timeout = r.Header.Get(timeoutHeader)
+			return http.DefaultTransport.RoundTrip(r)
+		})}))
+
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+
+		_, err := Call[int, struct{}](ctx, c, "TestServiceSlow", "Wait", 1)
+		assertNoError(t, err)
+
+		ms, err := strconv.Atoi(timeout)
+		assertNoError(t, err)
+		assertEqual(t, true, ms > 50000 && ms <= 60000)
+	})
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
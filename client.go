@@ -1,10 +1,16 @@
 package turborpc
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
 	"os"
 	"reflect"
+	"regexp"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 
 	_ "embed"
@@ -18,6 +24,9 @@ var javascriptTemplateText string
 //go:embed typescript.tmpl
 var typescriptTemplateText string
 
+//go:embed python.tmpl
+var pythonTemplateText string
+
 type sourceClient struct {
 	ContentType string
 	SourceCode  string
@@ -74,8 +83,18 @@ func (rpc *Server) writeClientSourceCode(client clientGenerator, filePath string
 // a class. The method names are converted from pascal case to camel case
 // i.e "MyMethod" becomes "myMethod". There is also a class containing all
 // services with the name RPC. The classes are instantiated with the
-// URL endpoint of the rpc http server and optional headers that are passed to
-// the server.
+// URL endpoint of the rpc http server, optional headers that are passed to
+// the server and optional client options. The client option "batch" makes
+// the client coalesce calls made in the same tick into a single batch request,
+// and the client option "transport" set to a WebSocketTransport makes the
+// client call methods over a WebSocket connection, see WithWebSocket. The
+// client option "codec" encodes single calls with a binary codec such as
+// MessagePack or CBOR, it is an object with a content type and encode and
+// decode functions, e.g. wrapping a MessagePack library. The client option
+// "timeout" is the number of milliseconds the server may spend on each call,
+// see WithTimeout. Methods take an optional AbortSignal that cancels the call.
+// Methods of queries, see WithMethodQuery, are called with GET. Streaming
+// methods return an AsyncIterable.
 func (rpc *Server) TypeScriptClient() string {
 	return rpc.clientSourceCode(newTypeScriptClient())
 }
@@ -146,3 +165,363 @@ func generateClientFromTemplate(templateText string, metadata serverMetadata) st
 
 	return sb.String()
 }
+
+type pythonClient struct {
+}
+
+func newPythonClient() (pc pythonClient) {
+	return pc
+}
+
+func (c pythonClient) GenerateClient(metadata serverMetadata) sourceClient {
+	src, err := c.generate(metadata)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return sourceClient{
+		ContentType: "text/x-python",
+		SourceCode:  src,
+	}
+}
+
+// generate returns the source code of the client.
+func (c pythonClient) generate(metadata serverMetadata) (string, error) {
+	if err := checkPythonClientNames(metadata); err != nil {
+		return "", err
+	}
+
+	g := newPythonTypes(metadata)
+
+	for _, typ := range metadata.types() {
+		if typ != nil {
+			g.typeOf(typ)
+		}
+	}
+
+	funcs := template.FuncMap{
+		"snakeCase": snakeCase,
+		"typeOf":    g.typeOf,
+		"isVoid":    isVoid,
+	}
+
+	tmpl := template.Must(template.New("").Funcs(funcs).Parse(pythonTemplateText))
+
+	var sb strings.Builder
+	_ = tmpl.Execute(&sb, map[string]any{
+		"DatePrefix": datePrefix,
+		"Metadata":   metadata,
+		"Symbols":    strings.Join(g.declarations, "\n\n\n"),
+	})
+
+	return sb.String(), nil
+}
+
+// checkPythonClientNames checks that the server and its services can be
+// declared in a Python client, i.e. their names are identifiers that do not
+// shadow the names declared or imported by the client, the attributes of the
+// services on the RPC class do not collide, and no two methods of a service
+// have the same snake case name. The members of the service classes start with
+// an underscore so they cannot collide with methods.
+func checkPythonClientNames(metadata serverMetadata) error {
+	declared := make(map[string]bool)
+
+	for _, name := range pythonReserved {
+		declared[name] = true
+	}
+
+	attributes := map[string]bool{"client": true, "version": true}
+
+	declare := func(name string) error {
+		if !token.IsIdentifier(name) || pythonKeywords[name] {
+			return fmt.Errorf("%w: %q is not a Python identifier", errPythonClient, name)
+		}
+
+		if declared[name] {
+			return fmt.Errorf("%w: %q is declared twice", errPythonClient, name)
+		}
+
+		declared[name] = true
+
+		return nil
+	}
+
+	if err := declare(metadata.Name); err != nil {
+		return err
+	}
+
+	for _, s := range metadata.Services {
+		if err := declare(s.Name); err != nil {
+			return err
+		}
+
+		attr := snakeCase(s.Name)
+
+		if attributes[attr] {
+			return fmt.Errorf("%w: the attribute %q of %q is declared twice", errPythonClient, attr, s.Name)
+		}
+
+		attributes[attr] = true
+
+		methods := make(map[string]string)
+
+		for _, m := range s.Methods {
+			def := snakeCase(m.Name)
+
+			if other, ok := methods[def]; ok {
+				return fmt.Errorf("%w: the methods %q and %q of %q are both named %q", errPythonClient, other, m.Name, s.Name, def)
+			}
+
+			methods[def] = m.Name
+		}
+	}
+
+	return nil
+}
+
+// PythonClient returns source code for a Python client that only uses the
+// standard library. Types are declared as TypedDicts, dates are revived into
+// datetime objects and errors are raised as an RPCError with a code, status
+// and details. Each service is a class with a method for each of the
+// service's methods, whose names are converted from pascal case to snake case
+// i.e "MyMethod" becomes "my_method". There is also a class containing all
+// services with the name RPC, which is instantiated with the URL endpoint of
+// the rpc http server, optional headers that are passed to the server, an
+// optional timeout in seconds, see WithTimeout, and an optional function
+// called on version mismatches. Streaming methods are not included.
+// PythonClient panics if a service name is not a Python identifier or
+// collides with a name declared by the client, e.g. Client or RPCError, see
+// WritePythonClient.
+func (rpc *Server) PythonClient() string {
+	return rpc.clientSourceCode(newPythonClient())
+}
+
+// WritePythonClient writes a Python client to a file, see PythonClient. It
+// returns an error if the client cannot be generated.
+func (rpc *Server) WritePythonClient(filePath string) error {
+	src, err := newPythonClient().generate(rpc.metadata())
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, []byte(src), 0600)
+}
+
+// MustWritePythonClient generates a Python client and writes it to the specified file path.
+// If an error occurs during the generation or writing process, it will panic.
+func (rpc *Server) MustWritePythonClient(filePath string) {
+	if err := rpc.WritePythonClient(filePath); err != nil {
+		panic(err)
+	}
+}
+
+// snakeCase converts a name from pascal case to snake case, e.g. "GetHTTPStatus"
+// becomes "get_http_status". Python keywords get a trailing underscore.
+func snakeCase(s string) string {
+	rs := []rune(s)
+
+	var sb strings.Builder
+
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) && (!unicode.IsUpper(rs[i-1]) || i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+			sb.WriteRune('_')
+		}
+
+		sb.WriteRune(unicode.ToLower(r))
+	}
+
+	if pythonKeywords[sb.String()] {
+		sb.WriteRune('_')
+	}
+
+	return sb.String()
+}
+
+var errPythonClient = errors.New("cannot generate Python client")
+
+// pythonReserved are the names declared or imported by the Python client,
+// which services and types must not shadow.
+var pythonReserved = []string{
+	"Any", "Callable", "Client", "DATE_PREFIX", "Dict", "List", "Literal", "Optional", "RPCError",
+	"RPCErrorCode", "TypedDict", "VERSION", "_DATE", "_encode", "_parse_date", "_revive",
+	"_to_error", "annotations", "datetime", "json", "re", "urllib",
+}
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true,
+	"elif": true, "else": true, "except": true, "finally": true, "for": true, "from": true,
+	"global": true, "if": true, "import": true, "in": true, "is": true, "lambda": true,
+	"nonlocal": true, "not": true, "or": true, "pass": true, "raise": true, "return": true,
+	"try": true, "while": true, "with": true, "yield": true,
+}
+
+var pythonIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var (
+	typeOfDate          = reflect.TypeOf(Date{})
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// pythonTypes declares Go types as Python TypedDicts.
+type pythonTypes struct {
+	names        map[reflect.Type]string
+	used         map[string]bool
+	declarations []string
+}
+
+func newPythonTypes(metadata serverMetadata) *pythonTypes {
+	used := map[string]bool{metadata.Name: true}
+
+	for _, name := range pythonReserved {
+		used[name] = true
+	}
+
+	for _, s := range metadata.Services {
+		used[s.Name] = true
+	}
+
+	return &pythonTypes{
+		names: make(map[reflect.Type]string),
+		used:  used,
+	}
+}
+
+// typeOf returns the Python type annotation of a type, declaring the struct
+// types it refers to.
+func (g *pythonTypes) typeOf(t reflect.Type) string {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		return "Optional[" + g.typeOf(t.Elem()) + "]"
+	case t == typeOfDate:
+		return "datetime.datetime"
+	case t == typeOfTime:
+		return "str"
+	case t.Kind() == reflect.Struct && t.Name() != "" && !t.Implements(typeOfJSONMarshaler):
+		return g.declare(t)
+	case t.Implements(typeOfJSONMarshaler):
+		// NonNullSlice and NonNullMap are never null.
+		if t.PkgPath() == typeOfDate.PkgPath() && (t.Kind() == reflect.Slice || t.Kind() == reflect.Map) {
+			return g.kindOf(t)
+		}
+
+		return "Any"
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		return "Optional[" + g.kindOf(t) + "]"
+	}
+
+	return g.kindOf(t)
+}
+
+// kindOf returns the Python type annotation of a type by its kind.
+func (g *pythonTypes) kindOf(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "str"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "str"
+		}
+
+		return "List[" + g.typeOf(t.Elem()) + "]"
+	case reflect.Array:
+		return "List[" + g.typeOf(t.Elem()) + "]"
+	case reflect.Map:
+		return "Dict[str, " + g.typeOf(t.Elem()) + "]"
+	case reflect.Struct:
+		return "Dict[str, Any]"
+	default:
+		return "Any"
+	}
+}
+
+// declare declares a struct type as a TypedDict and returns its name.
+func (g *pythonTypes) declare(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := g.name(t)
+	g.names[t] = name
+
+	var fields []pythonField
+
+	for _, f := range marshaledFields(t) {
+		typ := g.typeOf(f.typ)
+
+		if f.asString {
+			typ = "str"
+		}
+
+		fields = append(fields, pythonField{name: f.name, typ: typ, optional: f.omitempty})
+	}
+
+	total := true
+	classSyntax := true
+
+	for _, f := range fields {
+		if f.optional {
+			total = false
+		}
+
+		if !pythonIdentifier.MatchString(f.name) || pythonKeywords[f.name] {
+			classSyntax = false
+		}
+	}
+
+	var sb strings.Builder
+
+	if classSyntax {
+		fmt.Fprintf(&sb, "class %s(TypedDict", name)
+
+		if !total {
+			sb.WriteString(", total=False")
+		}
+
+		sb.WriteString("):\n")
+
+		for _, f := range fields {
+			fmt.Fprintf(&sb, "    %s: %s\n", f.name, f.typ)
+		}
+
+		if len(fields) == 0 {
+			sb.WriteString("    pass\n")
+		}
+	} else {
+		fmt.Fprintf(&sb, "%s = TypedDict(\n    %q,\n    {\n", name, name)
+
+		for _, f := range fields {
+			fmt.Fprintf(&sb, "        %q: %q,\n", f.name, f.typ)
+		}
+
+		fmt.Fprintf(&sb, "    },\n    total=%s,\n)\n", map[bool]string{true: "True", false: "False"}[total])
+	}
+
+	g.declarations = append(g.declarations, strings.TrimSuffix(sb.String(), "\n"))
+
+	return name
+}
+
+// name returns an unused Python name for a named type.
+func (g *pythonTypes) name(t reflect.Type) string {
+	return typeName(t, g.used)
+}
+
+type pythonField struct {
+	name     string
+	typ      string
+	optional bool
+}
@@ -1,15 +1,26 @@
 package turborpc
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"go/parser"
+	"go/token"
+	htmltemplate "html/template"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"text/template"
+	"time"
 )
 
 var runClientTests = os.Getenv("RUN_CLIENT_TESTS") == "yes"
@@ -93,7 +104,265 @@ func TestTypeScriptClient(t *testing.T) {
 	})
 }
 
-func TestGeneratedJavaScriptClient(t *testing.T) {
+type testUnexportedItem struct {
+	Name string `json:"name"`
+}
+
+type TestServiceUnexported struct{}
+
+func (s *TestServiceUnexported) Get(ctx context.Context) (testUnexportedItem, error) {
+	return testUnexportedItem{}, nil
+}
+
+type TestServiceProfile struct{}
+
+func (s *TestServiceProfile) Name(ctx context.Context) (string, error) {
+	return "profile", nil
+}
+
+func (s *TestServiceProfile) Version(ctx context.Context) (string, error) {
+	return "v1", nil
+}
+
+func (s *TestServiceProfile) Client(ctx context.Context) (string, error) {
+	return "python", nil
+}
+
+type TestServiceSnake struct{}
+
+func (s *TestServiceSnake) GetHTTP(ctx context.Context) error {
+	return nil
+}
+
+func (s *TestServiceSnake) GetHttp(ctx context.Context) error {
+	return nil
+}
+
+func TestGoClient(t *testing.T) {
+	t.Run("client should be stable", func(t *testing.T) {
+		testClientStability(t, newGoClient("api"))
+	})
+
+	t.Run("write client", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestService2{})
+		rpc.Register(&TestService1{})
+
+		filePath := fmt.Sprintf("run-%d.go.txt", rand.Int())
+		err := rpc.WriteGoClient(filePath, "api")
+		t.Cleanup(func() {
+			os.Remove(filePath)
+		})
+
+		assertNoError(t, err)
+	})
+
+	t.Run("types", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.MustRegister(&TestServiceShipment{})
+		rpc.MustRegister(&TestServiceSlow{})
+
+		client := rpc.GoClient("api")
+
+		_, err := parser.ParseFile(token.NewFileSet(), "client.go", client, 0)
+		assertNoError(t, err)
+
+		assertEqual(t, true, strings.Contains(client, "package api\n"))
+		assertEqual(t, true, strings.Contains(client, `turborpc "github.com/turborpc/turborpc"`))
+		assertEqual(t, true, strings.Contains(client, "func (s *TestServiceShipment) Ship(ctx context.Context, input turborpc.TestItem) (turborpc.TestShipment, error)"))
+		assertEqual(t, true, strings.Contains(client, "func (s *TestServiceSlow) Wait(ctx context.Context, input int) error"))
+		assertEqual(t, false, strings.Contains(client, "Watch"))
+	})
+
+	t.Run("type names", func(t *testing.T) {
+		g := newGoTypes()
+
+		for _, tt := range []struct {
+			typ  any
+			want string
+		}{
+			{0, "int"},
+			{[]string{}, "[]string"},
+			{map[string]*TestItem{}, "map[string]*turborpc.TestItem"},
+			{[2]Date{}, "[2]turborpc.Date"},
+			{NonNullSlice[TestItem]{}, "turborpc.NonNullSlice[turborpc.TestItem]"},
+			{struct {
+				A    int `json:"a"`
+				Date `json:"date"`
+			}{}, "struct{ A int `json:\"a\"`; turborpc.Date `json:\"date\"` }"},
+			{new(any), "*any"},
+			{http.Header{}, "http.Header"},
+			{new(template.Template), "*template.Template"},
+			{new(htmltemplate.Template), "*template2.Template"},
+		} {
+			assertEqual(t, tt.want, g.typeOf(reflect.TypeOf(tt.typ)))
+		}
+
+		assertEqual(t, fmt.Sprint([]goImport{
+			{Name: "turborpc", Path: "github.com/turborpc/turborpc"},
+			{Name: "template2", Path: "html/template"},
+			{Name: "http", Path: "net/http"},
+			{Name: "template", Path: "text/template"},
+		}), fmt.Sprint(g.sortedImports()))
+	})
+
+	t.Run("service names", func(t *testing.T) {
+		for _, name := range []string{"testService", "Test-Service", "Client", "Version", "New"} {
+			rpc := newTestServer()
+			assertNoError(t, rpc.RegisterName(name, &TestService1{}))
+
+			err := rpc.WriteGoClient(filepath.Join(t.TempDir(), "client.go"), "api")
+			assertErrorIs(t, errGoClient, err)
+
+			assertPanics(t, func() {
+				rpc.GoClient("api")
+			})
+		}
+
+		rpc := newTestServer()
+		assertNoError(t, rpc.RegisterName("Item", &TestService1{}))
+		assertNoError(t, rpc.RegisterName("NewItem", &TestService2{}))
+
+		err := rpc.WriteGoClient(filepath.Join(t.TempDir(), "client.go"), "api")
+		assertErrorIs(t, errGoClient, err)
+	})
+
+	t.Run("unexported type", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.MustRegister(&TestServiceUnexported{})
+
+		err := rpc.WriteGoClient(filepath.Join(t.TempDir(), "client.go"), "api")
+		assertErrorIs(t, errGoClient, err)
+
+		g := newGoTypes()
+		g.typeOf(reflect.TypeOf(NonNullSlice[testUnexportedItem]{}))
+		assertErrorIs(t, errGoClient, g.err)
+	})
+
+	t.Run("package main", func(t *testing.T) {
+		g := newGoTypes()
+		g.check("main", "Item")
+		assertErrorIs(t, errGoClient, g.err)
+	})
+
+	t.Run("invalid source", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.MustRegister(&TestService1{})
+
+		err := rpc.WriteGoClient(filepath.Join(t.TempDir(), "client.go"), "my-api")
+		assertErrorIs(t, errGoClient, err)
+
+		assertPanics(t, func() {
+			rpc.GoClient("my-api")
+		})
+	})
+}
+
+func TestPythonClient(t *testing.T) {
+	t.Run("client should be stable", func(t *testing.T) {
+		testClientStability(t, pythonClient{})
+	})
+
+	t.Run("write client", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestService2{})
+		rpc.Register(&TestService1{})
+
+		filePath := fmt.Sprintf("run-%d.py", rand.Int())
+		err := rpc.WritePythonClient(filePath)
+		t.Cleanup(func() {
+			os.Remove(filePath)
+		})
+
+		assertNoError(t, err)
+	})
+
+	t.Run("service names", func(t *testing.T) {
+		for _, name := range []string{"Client", "RPCError", "RPCErrorCode", "List", "VERSION", "Version", "None", "Test-Service"} {
+			rpc := newTestServer()
+			assertNoError(t, rpc.RegisterName(name, &TestService1{}))
+
+			err := rpc.WritePythonClient(filepath.Join(t.TempDir(), "client.py"))
+			assertErrorIs(t, errPythonClient, err)
+
+			assertPanics(t, func() {
+				rpc.PythonClient()
+			})
+		}
+	})
+
+	t.Run("method names", func(t *testing.T) {
+		rpc := newTestServer()
+		assertNoError(t, rpc.Register(&TestServiceSnake{}))
+
+		err := rpc.WritePythonClient(filepath.Join(t.TempDir(), "client.py"))
+		assertErrorIs(t, errPythonClient, err)
+	})
+
+	t.Run("reserved type names", func(t *testing.T) {
+		type List struct {
+			Name string `json:"name"`
+		}
+
+		g := newPythonTypes(serverMetadata{Name: "RPC"})
+
+		assertEqual(t, false, g.typeOf(reflect.TypeOf(List{})) == "List")
+	})
+
+	t.Run("snake case", func(t *testing.T) {
+		for s, want := range map[string]string{
+			"Three":         "three",
+			"SetAge":        "set_age",
+			"GetHTTPStatus": "get_http_status",
+			"TestService1":  "test_service1",
+			"Import":        "import_",
+		} {
+			assertEqual(t, want, snakeCase(s))
+		}
+	})
+
+	t.Run("types", func(t *testing.T) {
+		type Embedded struct {
+			ID int `json:"id"`
+		}
+
+		type TestNote struct {
+			Embedded
+			Text     string    `json:"text,omitempty"`
+			Count    int64     `json:"count,string"`
+			Author   *TestItem `json:"first-author"`
+			Tags     []string  `json:"tags"`
+			Labels   NonNullMap[string, float64]
+			Data     []byte `json:"data"`
+			Skipped  string `json:"-"`
+			internal string
+		}
+
+		g := newPythonTypes(serverMetadata{Name: "RPC"})
+
+		assertEqual(t, "Optional[List[TestNote]]", g.typeOf(reflect.TypeOf([]TestNote{})))
+		assertEqual(t, "Optional[datetime.datetime]", g.typeOf(reflect.TypeOf(new(Date))))
+		assertEqual(t, `class TestItem(TypedDict):
+    name: str`, g.declarations[0])
+		assertEqual(t, `TestNote = TypedDict(
+    "TestNote",
+    {
+        "id": "int",
+        "text": "str",
+        "count": "str",
+        "first-author": "Optional[TestItem]",
+        "tags": "Optional[List[str]]",
+        "Labels": "Dict[str, float]",
+        "data": "Optional[str]",
+    },
+    total=False,
+)`, g.declarations[1])
+	})
+}
+
+func TestGeneratedPythonClient(t *testing.T) {
 	if !runClientTests {
 		t.Skip()
 	}
@@ -104,6 +373,374 @@ func TestGeneratedJavaScriptClient(t *testing.T) {
 		serverOptions []ServerOption
 		code          string
 		output        string
+		headers       map[string]string
+	}{
+		{
+			desc: "import",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+		},
+		{
+			desc: "call",
+			services: []any{
+				&TestService1{},
+			},
+			code:   `print(Client(URL).call("TestService1", "Three", 0))`,
+			output: "3",
+		},
+		{
+			desc: "rpc call",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			code:   `rpc = RPC(URL); print(rpc.test_service1.three(0), rpc.test_service2.one())`,
+			output: "3 None",
+		},
+		{
+			desc: "method names",
+			services: []any{
+				&TestServiceProfile{},
+			},
+			code:   `s = RPC(URL).test_service_profile; print(s.name(), s.version(), s.client())`,
+			output: "profile v1 python",
+		},
+		{
+			desc: "date",
+			services: []any{
+				&TestServiceShipment{},
+			},
+			code:   `s = TestServiceShipment(Client(URL)).ship({"name": "book"}); print(s["name"], s["shipped"].isoformat(), s["tags"])`,
+			output: "book 2024-01-02T03:04:05+00:00 []",
+		},
+		{
+			desc: "version mismatch",
+			services: []any{
+				&TestService1{},
+			},
+			code:   `rpc = RPC(URL, on_version_mismatch=lambda c, s: print("mismatch")); rpc.client.client_version = "wrong version"; rpc.test_service1.one()`,
+			output: "mismatch",
+		},
+		{
+			desc: "no version mismatch",
+			services: []any{
+				&TestService1{},
+			},
+			code:   `RPC(URL, on_version_mismatch=lambda c, s: print("mismatch")).test_service1.one(); print("ok")`,
+			output: "ok",
+		},
+		{
+			desc: "error code",
+			services: []any{
+				&TestServiceCoded{},
+			},
+			code: `
+try:
+    TestServiceCoded(Client(URL)).out_of_stock()
+except RPCError as e:
+    print(e.code, e.status, e.details["available"], e.service, e.method)`,
+			output: "out_of_stock 409 2 TestServiceCoded OutOfStock",
+		},
+		{
+			desc: "validation error",
+			services: []any{
+				&TestServiceValidate{},
+			},
+			code: `
+try:
+    TestServiceValidate(Client(URL)).signup({"name": "", "age": 3})
+except RPCError as e:
+    print(e.code, ",".join(f["field"] for f in e.details["fields"]))`,
+			output: "invalid_input name,age",
+		},
+		{
+			desc: "timeout",
+			services: []any{
+				&TestServiceSlow{},
+			},
+			code: `
+try:
+    TestServiceSlow(Client(URL, timeout=0.01)).wait(1000)
+except RPCError as e:
+    print(e.code)`,
+			output: "timeout",
+		},
+		{
+			desc: "headers",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithCSRFProtection()},
+			code:          `print(RPC(URL, {"Authorization": "Bearer token"}).test_service1.three(0))`,
+			output:        "3",
+			headers:       map[string]string{"Authorization": "Bearer token", "Content-Type": "application/json"},
+		},
+	}
+
+	for _, tC := range testCases {
+		tC := tC
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			filePath := fmt.Sprintf("run-%d.py", rand.Int())
+
+			rpc := newTestServer(tC.serverOptions...)
+
+			for _, s := range tC.services {
+				rpc.Register(s)
+			}
+
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header
+				rpc.ServeHTTP(w, r)
+			}))
+
+			t.Cleanup(func() {
+				server.Close()
+			})
+
+			client := fmt.Sprintf("%s\n\nURL = %q\n\n%s\n", rpc.PythonClient(), server.URL, tC.code)
+
+			err := os.WriteFile(filePath, []byte(client), 0600)
+
+			assertNoError(t, err)
+
+			t.Cleanup(func() {
+				os.Remove(filePath)
+			})
+
+			output, err := execWithOutput("python3", filePath)
+
+			assertNoError(t, err)
+
+			assertEqual(t, tC.output, output)
+
+			for name, value := range tC.headers {
+				assertEqual(t, value, header.Get(name))
+			}
+		})
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	t.Run("document should be stable", func(t *testing.T) {
+		testClientStability(t, openAPI{})
+	})
+
+	t.Run("write document", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestService2{})
+		rpc.Register(&TestService1{})
+
+		filePath := fmt.Sprintf("run-%d.json", rand.Int())
+		err := rpc.WriteOpenAPI(filePath)
+		t.Cleanup(func() {
+			os.Remove(filePath)
+		})
+
+		assertNoError(t, err)
+	})
+
+	t.Run("document", func(t *testing.T) {
+		rpc := newTestServer(WithErrorCode("out_of_stock", TestStockDetails{}))
+		rpc.MustRegister(&TestService1{})
+		rpc.MustRegister(&TestServiceShipment{})
+		rpc.MustRegister(&TestServiceStream{})
+		rpc.MustRegister(&TestServiceQuery{}, WithMethod("Find", WithMethodQuery()))
+
+		var doc struct {
+			OpenAPI string `json:"openapi"`
+			Info    struct {
+				Version string `json:"version"`
+			} `json:"info"`
+			Paths map[string]map[string]struct {
+				OperationID string `json:"operationId"`
+				Parameters  []struct {
+					Ref  string `json:"$ref"`
+					Name string `json:"name"`
+					In   string `json:"in"`
+				} `json:"parameters"`
+				RequestBody *struct {
+					Content map[string]struct {
+						Schema map[string]any `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+				Responses map[string]struct {
+					Ref         string `json:"$ref"`
+					Description string `json:"description"`
+					Content     map[string]struct {
+						Schema struct {
+							Properties map[string]map[string]any `json:"properties"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"responses"`
+			} `json:"paths"`
+			Components struct {
+				Schemas map[string]struct {
+					Properties map[string]map[string]any `json:"properties"`
+					Required   []string                  `json:"required"`
+					AllOf      []struct {
+						If struct {
+							Properties map[string]map[string]any `json:"properties"`
+						} `json:"if"`
+						Then struct {
+							Properties map[string]map[string]any `json:"properties"`
+						} `json:"then"`
+					} `json:"allOf"`
+				} `json:"schemas"`
+			} `json:"components"`
+		}
+
+		assertNoError(t, json.Unmarshal([]byte(rpc.OpenAPI()), &doc))
+
+		assertEqual(t, "3.1.0", doc.OpenAPI)
+		assertEqual(t, rpc.version, doc.Info.Version)
+		assertEqual(t, 13, len(doc.Paths))
+
+		ship := doc.Paths["/?service=TestServiceShipment&method=Ship"]["post"]
+		assertEqual(t, "TestServiceShipment.Ship", ship.OperationID)
+		assertEqual(t, "#/components/parameters/Timeout", ship.Parameters[0].Ref)
+		assertEqual(t, "#/components/schemas/TestItem", ship.RequestBody.Content["application/json"].Schema["$ref"])
+		assertEqual(t, "#/components/schemas/TestShipment", ship.Responses["200"].Content["application/json"].Schema.Properties["output"]["$ref"])
+		assertEqual(t, "#/components/responses/Error", ship.Responses["default"].Ref)
+
+		one := doc.Paths["/?service=TestService1&method=One"]
+		assertEqual(t, 1, len(one))
+		assertEqual(t, true, one["post"].RequestBody == nil)
+		assertEqual(t, "null", one["post"].Responses["200"].Content["application/json"].Schema.Properties["output"]["type"])
+
+		find := doc.Paths["/?service=TestServiceQuery&method=Find"]
+		assertEqual(t, 2, len(find))
+		assertEqual(t, true, find["get"].RequestBody == nil)
+		assertEqual(t, "input", find["get"].Parameters[1].Name)
+		assertEqual(t, "query", find["get"].Parameters[1].In)
+
+		count := doc.Paths["/?service=TestServiceStream&method=Count"]["post"]
+		_, ok := count.Responses["200"].Content["text/event-stream"]
+		assertEqual(t, true, ok)
+		assertEqual(t, true, strings.Contains(count.Responses["200"].Description, `"end" event`))
+		assertEqual(t, true, strings.Contains(count.Responses["200"].Description, `"error" event`))
+
+		assertEqual(t, "string", doc.Components.Schemas["TestShipment"].Properties["name"]["type"])
+		assertEqual(t, "name,shipped,tags", strings.Join(doc.Components.Schemas["TestShipment"].Required, ","))
+
+		errResp := doc.Components.Schemas["ErrorResponse"]
+		assertEqual(t, "status,code,message", strings.Join(errResp.Required, ","))
+
+		details := make(map[string]any)
+		for _, c := range errResp.AllOf {
+			details[c.If.Properties["code"]["const"].(string)] = c.Then.Properties["details"]["$ref"]
+		}
+
+		assertEqual(t, "#/components/schemas/TestStockDetails", details["out_of_stock"])
+		assertEqual(t, "#/components/schemas/ValidationError", details[CodeInvalidInput])
+	})
+}
+
+func TestGeneratedGoClient(t *testing.T) {
+	if !runClientTests {
+		t.Skip()
+	}
+
+	rpc := newTestServer()
+	rpc.MustRegister(&TestService1{})
+	rpc.MustRegister(&TestServiceCoded{})
+
+	server := httptest.NewServer(rpc)
+	t.Cleanup(server.Close)
+
+	dir := fmt.Sprintf("run-%d", rand.Int())
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	assertNoError(t, os.MkdirAll(dir+"/api", 0700))
+	assertNoError(t, rpc.WriteGoClient(dir+"/api/client.go", "api"))
+
+	main := fmt.Sprintf(`package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/turborpc/turborpc"
+	"github.com/turborpc/turborpc/%s/api"
+)
+
+func main() {
+	ctx := context.Background()
+	rpc := api.New(%q, turborpc.WithVersionMismatchHandler(func(string, string) {
+		fmt.Println("mismatch")
+	}))
+
+	n, err := rpc.TestService1.Three(ctx, 1)
+	fmt.Println(n, err, rpc.TestService1.One(ctx))
+
+	var rpcErr *turborpc.RPCError
+	fmt.Println(errors.As(rpc.TestServiceCoded.OutOfStock(ctx), &rpcErr), rpcErr.Code)
+}
+`, dir, server.URL)
+
+	assertNoError(t, os.WriteFile(dir+"/main.go", []byte(main), 0600))
+
+	output, err := execWithOutput("go", "run", "./"+dir)
+
+	assertNoError(t, err)
+
+	assertEqual(t, "3 <nil> <nil>\ntrue out_of_stock", output)
+}
+
+// reversedJSONCodec is JSON with the bytes reversed, it stands in for a binary
+// codec in client tests.
+type reversedJSONCodec struct{}
+
+func reverseBytes(buf []byte) []byte {
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+
+	return buf
+}
+
+func (reversedJSONCodec) ContentType() string {
+	return "application/x-reversed-json"
+}
+
+func (reversedJSONCodec) Marshal(v any) ([]byte, error) {
+	buf, err := json.Marshal(v)
+
+	return reverseBytes(buf), err
+}
+
+func (reversedJSONCodec) Decode(r io.Reader, v any, strict bool) error {
+	buf, err := io.ReadAll(r)
+
+	if err != nil {
+		return err
+	}
+
+	return decodeJSON(bytes.NewReader(reverseBytes(buf)), v, strict)
+}
+
+const reversedJSONCodecJS = `{ contentType: "application/x-reversed-json", encode: (v) => new TextEncoder().encode(JSON.stringify(v)).reverse(), decode: (b) => JSON.parse(new TextDecoder().decode(b.reverse())) }`
+
+func TestGeneratedJavaScriptClient(t *testing.T) {
+	if !runClientTests {
+		t.Skip()
+	}
+
+	testCases := []struct {
+		desc           string
+		services       []any
+		serverOptions  []ServerOption
+		serviceOptions []ServiceOption
+		code           string
+		output         string
 	}{
 		{
 			desc: "type check",
@@ -220,6 +857,190 @@ func TestGeneratedJavaScriptClient(t *testing.T) {
 			code:   `const rpc = new RPC(URL); rpc.onVersionMismatch = () => console.log("no mismatch"); rpc.testService1.three(0);`,
 			output: "",
 		},
+		{
+			desc: "batch call",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			code:   `const rpc = new RPC(URL, {}, { batch: true }); Promise.all([rpc.testService1.three(0), rpc.testService2.three(0)]).then((res) => console.log(res.join(",")))`,
+			output: "3,3",
+		},
+		{
+			desc: "batch call error",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			code:   `const rpc = new RPC(URL, {}, { batch: true }); Promise.all([rpc.testService1.three(0), rpc.testService1.error("test").catch((e) => e.message)]).then((res) => console.log(res.join(",")))`,
+			output: "3,test",
+		},
+		{
+			desc: "batch split",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithMaxBatchSize(2)},
+			code:          `const rpc = new RPC(URL, {}, { batch: true }); Promise.all([rpc.testService1.three(0), rpc.testService1.three(0), rpc.testService1.three(0)]).then((res) => console.log(res.join(",")))`,
+			output:        "3,3,3",
+		},
+		{
+			desc: "batch per headers",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithAuthenticator(func(r *http.Request) (any, error) {
+				if r.Header.Get("Authorization") == "bad" {
+					return nil, ErrUnauthenticated
+				}
+
+				return nil, nil
+			})},
+			code:   `const options = { batch: true }; const good = new TestService1(URL, { Authorization: "good" }, options); const bad = new TestService1(URL, { Authorization: "bad" }, options); Promise.all([good.three(0), bad.three(0).catch((e) => e.code)]).then((res) => console.log(res.join(",")))`,
+			output: "3,unauthenticated",
+		},
+		{
+			desc: "stream",
+			services: []any{
+				&TestServiceStream{},
+			},
+			code:   `(async () => { const out = []; for await (const v of new TestServiceStream(URL).count(3)) { out.push(v); } console.log(out.join(",")); })()`,
+			output: "0,1,2",
+		},
+		{
+			desc: "stream error",
+			services: []any{
+				&TestServiceStream{},
+			},
+			code:   `(async () => { try { for await (const v of new TestServiceStream(URL).fail(1)) { console.log(v); } } catch (e) { console.log(e.message); } })()`,
+			output: "0\ntest",
+		},
+		{
+			desc: "validation error",
+			services: []any{
+				&TestServiceValidate{},
+			},
+			code:   `new TestServiceValidate(URL).signup({ name: "", age: 3 }).catch((e) => console.log(e.code, e.details.fields.map((f) => f.field).join()))`,
+			output: "invalid_input name,age",
+		},
+		{
+			desc: "codec",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithCodec(reversedJSONCodec{})},
+			code:          `new TestService1(URL, {}, { codec: ` + reversedJSONCodecJS + ` }).three(0).then((res) => console.log(res))`,
+			output:        "3",
+		},
+		{
+			desc: "codec error",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithCodec(reversedJSONCodec{})},
+			code:          `new TestService1(URL, {}, { codec: ` + reversedJSONCodecJS + ` }).error("test").catch((e) => console.log(e.message))`,
+			output:        "test",
+		},
+		{
+			desc: "error code",
+			services: []any{
+				&TestServiceCoded{},
+			},
+			code:   `new TestServiceCoded(URL).outOfStock().catch((e) => console.log(isRPCError(e), e.code, e.status, e.details.available))`,
+			output: "true out_of_stock 409 2",
+		},
+		{
+			desc: "query",
+			services: []any{
+				&TestServiceQuery{},
+			},
+			serviceOptions: []ServiceOption{WithMethod("Find", WithMethodQuery()), WithMethod("Count", WithMethodQuery())},
+			code:           `const f = fetch; globalThis.fetch = (url, init) => { console.log(init.method); return f(url, init); }; const s = new TestServiceQuery(URL); s.find("a & ü").then((res) => console.log(res.name)).then(() => s.count()).then((res) => console.log(res)).then(() => s.update("a"))`,
+			output:         "GET\na & ü\nGET\n3\nPOST",
+		},
+		{
+			desc: "timeout",
+			services: []any{
+				&TestServiceSlow{},
+			},
+			code:   `new TestServiceSlow(URL, {}, { timeout: 10 }).wait(5000).catch((e) => console.log(e.code, e.status))`,
+			output: "timeout 504",
+		},
+		{
+			desc: "websocket timeout",
+			services: []any{
+				&TestServiceSlow{},
+			},
+			serverOptions: []ServerOption{WithWebSocket()},
+			code:          `const transport = new WebSocketTransport(URL.replace("http", "ws")); new TestServiceSlow(URL, {}, { transport, timeout: 10 }).wait(5000).catch((e) => { console.log(e.code); transport.close(); })`,
+			output:        "timeout",
+		},
+		{
+			desc: "rate limit",
+			services: []any{
+				&TestService1{},
+			},
+			serviceOptions: []ServiceOption{WithMethod("Three", WithMethodRateLimit(1, time.Minute))},
+			code:           `const s = new TestService1(URL); s.three(0).then(() => s.three(0)).catch((e) => console.log(e.code, e.status, e.details.retryAfterMs > 0))`,
+			output:         "rate_limited 429 true",
+		},
+		{
+			desc: "forbidden",
+			services: []any{
+				&TestServiceAdmin{},
+			},
+			serverOptions:  []ServerOption{WithAuthenticator(testAuthenticator)},
+			serviceOptions: []ServiceOption{WithMethod("Delete", WithMethodPolicy(RequireRole("admin")))},
+			code:           `new TestServiceAdmin(URL, { Authorization: "a user" }).delete().catch((e) => console.log(e.code, e.status))`,
+			output:         "forbidden 403",
+		},
+		{
+			desc: "csrf protection",
+			services: []any{
+				&TestService1{},
+				&TestServiceStream{},
+			},
+			serverOptions: []ServerOption{WithCSRFProtection()},
+			code:          `(async () => { const out = [await new TestService1(URL).three(0), await new TestService1(URL, {}, { batch: true }).three(0)]; for await (const v of new TestServiceStream(URL).count(2)) { out.push(v); } console.log(out.join(",")); })()`,
+			output:        "3,3,0,1",
+		},
+		{
+			desc: "stream break",
+			services: []any{
+				&TestServiceStream{},
+			},
+			code:   `(async () => { for await (const v of new TestServiceStream(URL).count(3)) { console.log(v); break; } })()`,
+			output: "0",
+		},
+		{
+			desc: "websocket call",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			serverOptions: []ServerOption{WithWebSocket()},
+			code:          `const transport = new WebSocketTransport(URL.replace("http", "ws")); const rpc = new RPC(URL, {}, { transport }); Promise.all([rpc.testService1.three(0), rpc.testService1.error("test").catch((e) => e.message)]).then((res) => { console.log(res.join(",")); transport.close(); })`,
+			output:        "3,test",
+		},
+		{
+			desc: "websocket stream",
+			services: []any{
+				&TestServiceStream{},
+			},
+			serverOptions: []ServerOption{WithWebSocket()},
+			code:          `(async () => { const transport = new WebSocketTransport(URL.replace("http", "ws")); const out = []; for await (const v of new TestServiceStream(URL, {}, { transport }).count(3)) { out.push(v); } console.log(out.join(",")); transport.close(); })()`,
+			output:        "0,1,2",
+		},
+		{
+			desc: "websocket version mismatch",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			serverOptions: []ServerOption{WithWebSocket()},
+			code:          `const transport = new WebSocketTransport(URL.replace("http", "ws")); const rpc = new RPC(URL, {}, { transport }); rpc.testService1.clientVersion = "wrong version"; rpc.onVersionMismatch = () => console.log("mismatch"); rpc.testService1.three(0).then(() => transport.close());`,
+			output:        "mismatch",
+		},
 	}
 
 	for _, tC := range testCases {
@@ -232,7 +1053,7 @@ func TestGeneratedJavaScriptClient(t *testing.T) {
 			rpc := newTestServer(tC.serverOptions...)
 
 			for _, s := range tC.services {
-				rpc.Register(s)
+				rpc.Register(s, tC.serviceOptions...)
 			}
 
 			server := httptest.NewServer(rpc)
@@ -266,12 +1087,13 @@ func TestGeneratedTypeScriptClient(t *testing.T) {
 	}
 
 	testCases := []struct {
-		desc          string
-		services      []any
-		serverOptions []ServerOption
-		code          string
-		output        string
-		headers       map[string]string
+		desc           string
+		services       []any
+		serverOptions  []ServerOption
+		serviceOptions []ServiceOption
+		code           string
+		output         string
+		headers        map[string]string
 	}{
 		{
 			desc: "type check",
@@ -400,6 +1222,175 @@ func TestGeneratedTypeScriptClient(t *testing.T) {
 			code:   `const rpc = new RPC(URL); rpc.onVersionMismatch = () => console.log("no mismatch"); rpc.testService1.three(0);`,
 			output: "",
 		},
+		{
+			desc: "batch call",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			code:   `const rpc = new RPC(URL, {}, { batch: true }); Promise.all([rpc.testService1.three(0), rpc.testService2.three(0)]).then((res) => console.log(res.join(",")))`,
+			output: "3,3",
+		},
+		{
+			desc: "batch call error",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			code:   `const rpc = new RPC(URL, {}, { batch: true }); Promise.all([rpc.testService1.three(0), rpc.testService1.error("test").catch((e) => e.message)]).then((res) => console.log(res.join(",")))`,
+			output: "3,test",
+		},
+		{
+			desc: "batch split",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithMaxBatchSize(2)},
+			code:          `const rpc = new RPC(URL, {}, { batch: true }); Promise.all([rpc.testService1.three(0), rpc.testService1.three(0), rpc.testService1.three(0)]).then((res) => console.log(res.join(",")))`,
+			output:        "3,3,3",
+		},
+		{
+			desc: "batch per headers",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithAuthenticator(func(r *http.Request) (any, error) {
+				if r.Header.Get("Authorization") == "bad" {
+					return nil, ErrUnauthenticated
+				}
+
+				return nil, nil
+			})},
+			code:   `const options = { batch: true }; const good = new TestService1(URL, { Authorization: "good" }, options); const bad = new TestService1(URL, { Authorization: "bad" }, options); Promise.all([good.three(0), bad.three(0).catch((e) => e.code)]).then((res) => console.log(res.join(",")))`,
+			output: "3,unauthenticated",
+		},
+		{
+			desc: "stream",
+			services: []any{
+				&TestServiceStream{},
+			},
+			code:   `(async () => { const out = []; for await (const v of new TestServiceStream(URL).count(3)) { out.push(v); } console.log(out.join(",")); })()`,
+			output: "0,1,2",
+		},
+		{
+			desc: "stream error",
+			services: []any{
+				&TestServiceStream{},
+			},
+			code:   `(async () => { try { for await (const v of new TestServiceStream(URL).fail(1)) { console.log(v); } } catch (e) { console.log((e as Error).message); } })()`,
+			output: "0\ntest",
+		},
+		{
+			desc: "validation error",
+			services: []any{
+				&TestServiceValidate{},
+			},
+			code:   `new TestServiceValidate(URL).signup({ name: "", age: 3 }).catch((e: unknown) => { if (isRPCError(e) && e.code === "invalid_input") { console.log(e.details.fields.map((f) => f.field).join()); } })`,
+			output: "name,age",
+		},
+		{
+			desc: "codec",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithCodec(reversedJSONCodec{})},
+			code:          `const codec: Codec = ` + strings.ReplaceAll(strings.ReplaceAll(reversedJSONCodecJS, "(v)", "(v: unknown)"), "(b)", "(b: Uint8Array)") + `; new TestService1(URL, {}, { codec }).three(0).then((res) => console.log(res))`,
+			output:        "3",
+		},
+		{
+			desc: "error code",
+			services: []any{
+				&TestServiceCoded{},
+			},
+			serverOptions: []ServerOption{WithErrorCode("out_of_stock", TestStockDetails{})},
+			code:          `new TestServiceCoded(URL).outOfStock().catch((e: unknown) => { if (isRPCError(e) && e.code === "out_of_stock") { console.log(e.status, e.details.available); } })`,
+			output:        "409 2",
+		},
+		{
+			desc: "query",
+			services: []any{
+				&TestServiceQuery{},
+			},
+			serviceOptions: []ServiceOption{WithMethod("Find", WithMethodQuery()), WithMethod("Count", WithMethodQuery())},
+			code:           `const s = new TestServiceQuery(URL); s.find("a & ü").then((res) => console.log(res.name)).then(() => s.count()).then((res) => console.log(res))`,
+			output:         "a & ü\n3",
+		},
+		{
+			desc: "timeout",
+			services: []any{
+				&TestServiceSlow{},
+			},
+			code:   `new TestServiceSlow(URL, {}, { timeout: 10 }).wait(5000).catch((e: unknown) => { if (isRPCError(e)) { console.log(e.code, e.status); } })`,
+			output: "timeout 504",
+		},
+		{
+			desc: "rate limit",
+			services: []any{
+				&TestService1{},
+			},
+			serviceOptions: []ServiceOption{WithMethod("Three", WithMethodRateLimit(1, time.Minute))},
+			code:           `const s = new TestService1(URL); s.three(0).then(() => s.three(0)).catch((e: unknown) => { if (isRPCError(e) && e.code === "rate_limited") { console.log(e.status, e.details.retryAfterMs > 0); } })`,
+			output:         "429 true",
+		},
+		{
+			desc: "forbidden",
+			services: []any{
+				&TestServiceAdmin{},
+			},
+			serverOptions:  []ServerOption{WithAuthenticator(testAuthenticator)},
+			serviceOptions: []ServiceOption{WithMethod("Delete", WithMethodPolicy(RequireRole("admin")))},
+			code:           `new TestServiceAdmin(URL, { Authorization: "a user" }).delete().catch((e: unknown) => { if (isRPCError(e) && e.code === "forbidden") { console.log(e.status); } })`,
+			output:         "403",
+		},
+		{
+			desc: "csrf protection",
+			services: []any{
+				&TestService1{},
+			},
+			serverOptions: []ServerOption{WithCSRFProtection()},
+			code:          `new TestService1(URL).three(0).then((res) => console.log(res))`,
+			output:        "3",
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+		},
+		{
+			desc: "stream break",
+			services: []any{
+				&TestServiceStream{},
+			},
+			code:   `(async () => { for await (const v of new TestServiceStream(URL).count(3)) { console.log(v); break; } })()`,
+			output: "0",
+		},
+		{
+			desc: "websocket call",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			serverOptions: []ServerOption{WithWebSocket()},
+			code:          `const transport = new WebSocketTransport(URL.replace("http", "ws")); const rpc = new RPC(URL, {}, { transport }); Promise.all([rpc.testService1.three(0), rpc.testService1.error("test").catch((e) => e.message)]).then((res) => { console.log(res.join(",")); transport.close(); })`,
+			output:        "3,test",
+		},
+		{
+			desc: "websocket stream",
+			services: []any{
+				&TestServiceStream{},
+			},
+			serverOptions: []ServerOption{WithWebSocket()},
+			code:          `(async () => { const transport = new WebSocketTransport(URL.replace("http", "ws")); const out = []; for await (const v of new TestServiceStream(URL, {}, { transport }).count(3)) { out.push(v); } console.log(out.join(",")); transport.close(); })()`,
+			output:        "0,1,2",
+		},
+		{
+			desc: "websocket version mismatch",
+			services: []any{
+				&TestService1{},
+				&TestService2{},
+			},
+			serverOptions: []ServerOption{WithWebSocket()},
+			code:          `const transport = new WebSocketTransport(URL.replace("http", "ws")); const rpc = new RPC(URL, {}, { transport }); rpc.testService1.clientVersion = "wrong version"; rpc.onVersionMismatch = () => console.log("mismatch"); rpc.testService1.three(0).then(() => transport.close());`,
+			output:        "mismatch",
+		},
 	}
 
 	for _, tC := range testCases {
@@ -412,7 +1403,7 @@ func TestGeneratedTypeScriptClient(t *testing.T) {
 			rpc := newTestServer(tC.serverOptions...)
 
 			for _, s := range tC.services {
-				rpc.Register(s)
+				rpc.Register(s, tC.serviceOptions...)
 			}
 
 			var header http.Header
@@ -0,0 +1,368 @@
+package turborpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// A Codec encodes and decodes the bodies of method calls. The server picks
+// the codec of a request from its Content-Type header and the codec of the
+// response from its Accept header, see WithCodec. Batch requests, streaming
+// methods and WebSocket connections always use JSON.
+type Codec interface {
+	// ContentType returns the media type of the encoding, e.g.
+	// "application/json".
+	ContentType() string
+	// Marshal returns the encoding of v.
+	Marshal(v any) ([]byte, error)
+	// Decode decodes a single value read from r into v. If strict is set
+	// the codec should fail on fields that are not in v, see
+	// WithStrictDecoding.
+	Decode(r io.Reader, v any, strict bool) error
+}
+
+// Codecs built into the server. Struct fields are named by their json tags
+// with every codec.
+var (
+	JSONCodec        Codec = jsonCodec{}
+	MessagePackCodec Codec = &msgpackCodec{}
+	CBORCodec        Codec = cborCodec{}
+)
+
+// WithCodec adds a codec to the server, replacing any codec with the same
+// content type. The server has the JSON, MessagePack and CBOR codecs by
+// default, and requests without a known Content-Type are decoded as JSON.
+func WithCodec(codec Codec) ServerOption {
+	return func(r *Server) {
+		r.codecs[codec.ContentType()] = codec
+	}
+}
+
+func defaultCodecs() map[string]Codec {
+	codecs := make(map[string]Codec)
+
+	for _, codec := range []Codec{JSONCodec, MessagePackCodec, CBORCodec} {
+		codecs[codec.ContentType()] = codec
+	}
+
+	return codecs
+}
+
+// requestCodec returns the codec matching the Content-Type of the request,
+// or JSON if there is none.
+func (rpc *Server) requestCodec(r *http.Request) Codec {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if codec, ok := rpc.codecs[mediaType]; ok {
+		return codec
+	}
+
+	return JSONCodec
+}
+
+// responseCodec returns the codec accepted by the request with the highest
+// quality, the first one listed if several have it, or the codec of the
+// request if none is. Codecs with quality zero are never picked, and JSON is
+// used if the codec of the request has it.
+func (rpc *Server) responseCodec(r *http.Request, requestCodec Codec) Codec {
+	var best Codec
+	bestQ, refused := 0.0, false
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, q, err := parseAccept(accept)
+
+		if err != nil {
+			continue
+		}
+
+		if q == 0 {
+			refused = refused || mediaType == requestCodec.ContentType()
+			continue
+		}
+
+		if codec, ok := rpc.codecs[mediaType]; ok && q > bestQ {
+			best, bestQ = codec, q
+		}
+	}
+
+	switch {
+	case best != nil:
+		return best
+	case refused:
+		return JSONCodec
+	default:
+		return requestCodec
+	}
+}
+
+// parseAccept parses an element of an Accept or Accept-Encoding header into
+// its lower case value and its quality, which is 1 if it has none.
+func parseAccept(s string) (string, float64, error) {
+	value, params, err := mime.ParseMediaType(s)
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	q := 1.0
+
+	if v, ok := params["q"]; ok {
+		if q, err = strconv.ParseFloat(v, 64); err != nil {
+			return "", 0, err
+		}
+
+		if q < 0 || q > 1 {
+			return "", 0, fmt.Errorf("quality %s out of range", v)
+		}
+	}
+
+	return value, q, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v any, strict bool) error {
+	return decodeJSON(r, v, strict)
+}
+
+// msgpackCodec encodes with msgpack. It remembers which types may hold a
+// NonNullSlice or NonNullMap, see nonNull.
+type msgpackCodec struct {
+	types sync.Map
+}
+
+func (*msgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+func (c *msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+
+	if rv := c.nonNull(reflect.ValueOf(v)); rv.IsValid() {
+		v = rv.Interface()
+	}
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (*msgpackCodec) Decode(r io.Reader, v any, strict bool) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(strict)
+
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return errNoInput
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+var typeOfMsgpackEncoder = reflect.TypeOf((*msgpack.CustomEncoder)(nil)).Elem()
+
+// isNonNull reports whether a type is a NonNullSlice or NonNullMap.
+func isNonNull(t reflect.Type) bool {
+	return t.PkgPath() == typeOfDate.PkgPath() && (t.Kind() == reflect.Slice || t.Kind() == reflect.Map) && t.Implements(typeOfMsgpackEncoder)
+}
+
+// nonNull returns v with the nil NonNullSlice and NonNullMap values in it
+// replaced by empty ones. msgpack encodes a nil slice or map as nil without
+// calling its EncodeMsgpack method. The parts of v that hold such values are
+// copied rather than modified, and v is returned as is if it holds none.
+func (c *msgpackCodec) nonNull(v reflect.Value) reflect.Value {
+	if !v.IsValid() || !c.mayHoldNonNull(v.Type()) {
+		return v
+	}
+
+	t := v.Type()
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		i := reflect.New(t).Elem()
+		i.Set(c.nonNull(v.Elem()))
+
+		return i
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+
+		p := reflect.New(t.Elem())
+		p.Elem().Set(c.nonNull(v.Elem()))
+
+		return p
+	case reflect.Slice:
+		if v.IsNil() {
+			if isNonNull(t) {
+				return reflect.MakeSlice(t, 0, 0)
+			}
+
+			return v
+		}
+
+		s := reflect.MakeSlice(t, v.Len(), v.Len())
+
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(c.nonNull(v.Index(i)))
+		}
+
+		return s
+	case reflect.Array:
+		a := reflect.New(t).Elem()
+
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(c.nonNull(v.Index(i)))
+		}
+
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			if isNonNull(t) {
+				return reflect.MakeMap(t)
+			}
+
+			return v
+		}
+
+		m := reflect.MakeMapWithSize(t, v.Len())
+
+		for iter := v.MapRange(); iter.Next(); {
+			m.SetMapIndex(c.nonNull(iter.Key()), c.nonNull(iter.Value()))
+		}
+
+		return m
+	case reflect.Struct:
+		s := reflect.New(t).Elem()
+		s.Set(v)
+
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				s.Field(i).Set(c.nonNull(v.Field(i)))
+			}
+		}
+
+		return s
+	}
+
+	return v
+}
+
+// mayHoldNonNull reports whether values of a type may hold a NonNullSlice or
+// NonNullMap. Types with interfaces in them may hold any value.
+func (c *msgpackCodec) mayHoldNonNull(t reflect.Type) bool {
+	if ok, found := c.types.Load(t); found {
+		return ok.(bool)
+	}
+
+	ok := walkNonNull(t, make(map[reflect.Type]bool))
+	c.types.Store(t, ok)
+
+	return ok
+}
+
+func walkNonNull(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+
+	seen[t] = true
+
+	if isNonNull(t) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return walkNonNull(t.Elem(), seen)
+	case reflect.Map:
+		key := walkNonNull(t.Key(), seen)
+		return walkNonNull(t.Elem(), seen) || key
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() && walkNonNull(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// cborEncMode encodes times as RFC 3339 strings tagged as date/time, so
+// clients can decode a Date into a JavaScript Date.
+var cborEncMode, _ = cbor.EncOptions{
+	Time:    cbor.TimeRFC3339Nano,
+	TimeTag: cbor.EncTagRequired,
+}.EncMode()
+
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+}.DecMode()
+
+var cborStrictDecMode, _ = cbor.DecOptions{
+	DefaultMapType:    reflect.TypeOf(map[string]any(nil)),
+	ExtraReturnErrors: cbor.ExtraDecErrorUnknownField,
+}.DecMode()
+
+type cborCodec struct{}
+
+func (cborCodec) ContentType() string {
+	return "application/cbor"
+}
+
+func (cborCodec) Marshal(v any) ([]byte, error) {
+	return cborEncMode.Marshal(v)
+}
+
+func (cborCodec) Decode(r io.Reader, v any, strict bool) error {
+	decMode := cborDecMode
+
+	if strict {
+		decMode = cborStrictDecMode
+	}
+
+	if err := decMode.NewDecoder(r).Decode(v); err != nil {
+		if err == io.EOF {
+			return errNoInput
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package turborpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// CodeUnsupportedMediaType is the error code of requests with a body in an
+// encoding the server does not support.
+const CodeUnsupportedMediaType = "unsupported_media_type"
+
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errDecompressing       = errors.New("decompressing request")
+)
+
+// WithCompression makes the server compress responses of at least minBytes
+// bytes with gzip or deflate when the request's Accept-Encoding header
+// allows it. Responses to streaming methods and WebSocket messages are not
+// compressed. Request bodies compressed with gzip or deflate are always
+// accepted, see decompressRequest.
+func WithCompression(minBytes int) ServerOption {
+	return func(r *Server) {
+		r.compression = true
+		r.compressionMinBytes = minBytes
+	}
+}
+
+// decompressRequest replaces the body of a request that has a
+// Content-Encoding header with a reader of the decompressed body.
+func decompressRequest(r *http.Request) error {
+	var body io.ReadCloser
+	var err error
+
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		body, err = gzip.NewReader(r.Body)
+	case "deflate":
+		body, err = zlib.NewReader(r.Body)
+	default:
+		return fmt.Errorf("%w %q", errUnsupportedEncoding, r.Header.Get("Content-Encoding"))
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", errDecompressing, err)
+	}
+
+	r.Body = decompressedBody{body}
+	r.Header.Del("Content-Encoding")
+
+	return nil
+}
+
+// decompressedBody wraps errors from a corrupt request body in
+// errDecompressing.
+type decompressedBody struct {
+	io.ReadCloser
+}
+
+func (b decompressedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", errDecompressing, err)
+	}
+
+	return n, err
+}
+
+// acceptedEncoding returns the compression accepted by an Accept-Encoding
+// header with the highest quality, preferring gzip, or "" if none is. The
+// quality of "*" applies to the codings that are not listed.
+func acceptedEncoding(header string) string {
+	qs, star := make(map[string]float64), -1.0
+
+	for _, part := range strings.Split(header, ",") {
+		coding, q, err := parseAccept(part)
+
+		if err != nil {
+			continue
+		}
+
+		if coding == "*" {
+			star = q
+		} else {
+			qs[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+
+	for _, coding := range []string{"gzip", "deflate"} {
+		q, ok := qs[coding]
+
+		if !ok {
+			q = star
+		}
+
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+
+	return best
+}
+
+// compress compresses a response body if compression is enabled, the body
+// is large enough and the request accepts it. It sets the response headers
+// for the compression used.
+func (rpc *Server) compress(w http.ResponseWriter, r *http.Request, buf []byte) []byte {
+	if !rpc.compression {
+		return buf
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if len(buf) < rpc.compressionMinBytes {
+		return buf
+	}
+
+	encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+
+	var b bytes.Buffer
+	var cw io.WriteCloser
+
+	switch encoding {
+	case "gzip":
+		cw = gzip.NewWriter(&b)
+	case "deflate":
+		cw = zlib.NewWriter(&b)
+	default:
+		return buf
+	}
+
+	cw.Write(buf)
+	cw.Close()
+
+	w.Header().Set("Content-Encoding", encoding)
+
+	return b.Bytes()
+}
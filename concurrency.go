@@ -0,0 +1,157 @@
+package turborpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CodeUnavailable is the error code of calls that are shed because the
+// server is overloaded.
+const CodeUnavailable = "unavailable"
+
+// ErrOverloaded is the error of calls that are shed by a concurrency limit,
+// they are answered with status code 503 and a Retry-After header.
+var ErrOverloaded = errors.New("too many concurrent calls")
+
+// overloadedRetryAfter is the time clients are asked to wait before retrying
+// a shed call.
+const overloadedRetryAfter = time.Second
+
+// WithConcurrencyLimit limits the number of method calls on the server that
+// run at the same time to n. When all n calls are running up to queue more
+// calls wait for one to finish, and further calls are shed with
+// ErrOverloaded. Waiting calls give up when their context is done, see
+// WithTimeout. Streaming methods count as running until the stream ends. If n
+// is zero or less there is no limit.
+func WithConcurrencyLimit(n, queue int) ServerOption {
+	return func(r *Server) {
+		r.limiter = newLimiter(n, queue)
+	}
+}
+
+// WithServiceConcurrencyLimit limits the number of calls to the service that
+// run at the same time, see WithConcurrencyLimit.
+func WithServiceConcurrencyLimit(n, queue int) ServiceOption {
+	return func(s *service) {
+		s.limiter = newLimiter(n, queue)
+	}
+}
+
+// WithMethodConcurrencyLimit limits the number of calls to the method that
+// run at the same time, see WithConcurrencyLimit.
+func WithMethodConcurrencyLimit(n, queue int) MethodOption {
+	return func(m *method) {
+		m.limiter = newLimiter(n, queue)
+	}
+}
+
+// A retryError is an error that clients may retry after some time. It is
+// answered with a Retry-After header.
+type retryError struct {
+	err    error
+	status int
+	after  time.Duration
+}
+
+func (e *retryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryError) Unwrap() error {
+	return e.err
+}
+
+func (e *retryError) HTTPStatus() int {
+	return e.status
+}
+
+// setRetryAfter sets the Retry-After header of the response to an error
+// that may be retried.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var retryErr *retryError
+
+	if errors.As(err, &retryErr) {
+		secs := int((retryErr.after + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+}
+
+// limiter limits the number of concurrent calls.
+type limiter struct {
+	slots  chan struct{}
+	mu     sync.Mutex
+	queue  int
+	queued int
+}
+
+// newLimiter returns a limiter of n concurrent calls, or nil if n is zero or
+// less and there is no limit.
+func newLimiter(n, queue int) *limiter {
+	if n <= 0 {
+		return nil
+	}
+
+	return &limiter{
+		slots: make(chan struct{}, n),
+		queue: queue,
+	}
+}
+
+// acquire waits for a slot for a call, it must be released by release.
+func (l *limiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.mu.Lock()
+	if l.queued >= l.queue {
+		l.mu.Unlock()
+		return &retryError{err: ErrOverloaded, status: http.StatusServiceUnavailable, after: overloadedRetryAfter}
+	}
+	l.queued++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	<-l.slots
+}
+
+// acquireLimits acquires a slot from every limiter of a call, starting with
+// the innermost so a call waiting for a method slot does not hold a slot of
+// the server. It returns a function releasing the slots.
+func acquireLimits(ctx context.Context, limiters []*limiter) (func(), error) {
+	for i := len(limiters) - 1; i >= 0; i-- {
+		if err := limiters[i].acquire(ctx); err != nil {
+			for _, l := range limiters[i+1:] {
+				l.release()
+			}
+
+			return nil, err
+		}
+	}
+
+	return func() {
+		for _, l := range limiters {
+			l.release()
+		}
+	}, nil
+}
@@ -0,0 +1,114 @@
+package turborpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// httpCallKey is the context key of the HTTP request of a method call and
+// the headers the method sets on its response.
+type httpCallKey struct{}
+
+type httpCall struct {
+	request      *http.Request
+	mu           sync.Mutex
+	header       http.Header
+	cacheControl string
+}
+
+// withHTTPCall returns a context for a method call made by the request.
+func withHTTPCall(ctx context.Context, r *http.Request) (context.Context, *httpCall) {
+	c := &httpCall{
+		request: r,
+		header:  make(http.Header),
+	}
+
+	return context.WithValue(ctx, httpCallKey{}, c), c
+}
+
+// withRequest returns a context for method calls made by the request that
+// cannot set response headers, like the calls in a batch.
+func withRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, httpCallKey{}, &httpCall{request: r})
+}
+
+func httpCallFromContext(ctx context.Context) *httpCall {
+	c, _ := ctx.Value(httpCallKey{}).(*httpCall)
+
+	return c
+}
+
+// responseCall returns the call of the context if it can set response
+// headers.
+func responseCall(ctx context.Context) *httpCall {
+	if c := httpCallFromContext(ctx); c != nil && c.header != nil {
+		return c
+	}
+
+	return nil
+}
+
+// RequestFromContext returns the HTTP request of a method call, or nil if
+// the context is not from a method call. For calls in a batch it is the
+// batch request, and for calls over a WebSocket it is the request that
+// opened the connection. The request body must not be read.
+func RequestFromContext(ctx context.Context) *http.Request {
+	if c := httpCallFromContext(ctx); c != nil {
+		return c.request
+	}
+
+	return nil
+}
+
+// SetHeader sets a header of the response to a method call, replacing any
+// value set before. Headers are sent with both successful and error
+// responses, and with the events of a streaming method if set before its
+// first value is sent. It does nothing for calls in a batch or over a
+// WebSocket.
+func SetHeader(ctx context.Context, key, value string) {
+	if c := responseCall(ctx); c != nil {
+		c.mu.Lock()
+		c.header.Set(key, value)
+		c.mu.Unlock()
+	}
+}
+
+// SetCookie adds a Set-Cookie header to the response to a method call, see
+// SetHeader. Invalid cookies are silently dropped.
+func SetCookie(ctx context.Context, cookie *http.Cookie) {
+	if v := cookie.String(); v != "" {
+		if c := responseCall(ctx); c != nil {
+			c.mu.Lock()
+			c.header.Add("Set-Cookie", v)
+			c.mu.Unlock()
+		}
+	}
+}
+
+// SetCacheControl sets the Cache-Control header of the response to a method
+// call, e.g. "public, max-age=60". Unlike headers set with SetHeader it is
+// only sent if the call succeeds. It does nothing for calls in a batch or
+// over a WebSocket.
+func SetCacheControl(ctx context.Context, value string) {
+	if c := responseCall(ctx); c != nil {
+		c.mu.Lock()
+		c.cacheControl = value
+		c.mu.Unlock()
+	}
+}
+
+// writeHeader adds the headers set by the method to the response, ok is
+// whether the call succeeded.
+func (c *httpCall) writeHeader(w http.ResponseWriter, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, v := range c.header {
+		w.Header()[k] = v
+	}
+
+	if ok && c.cacheControl != "" {
+		w.Header().Set("Cache-Control", c.cacheControl)
+	}
+}
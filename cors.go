@@ -0,0 +1,127 @@
+package turborpc
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsHeaders are the request headers the generated clients send, and the
+// header accepted by WithCSRFProtection, they are always allowed in
+// cross-origin requests.
+var corsHeaders = []string{"Accept", "Authorization", "Content-Encoding", "Content-Type", "If-None-Match", "X-Requested-With", timeoutHeader}
+
+// corsExposedHeaders are the response headers the generated clients read.
+var corsExposedHeaders = []string{"X-Server-Version", "Retry-After", "ETag"}
+
+// CORS is the cross-origin resource sharing configuration of a server, see
+// WithCORS.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to call the server, e.g.
+	// "https://example.com". The origin "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedHeaders are request headers allowed besides the headers sent by
+	// the generated clients, e.g. a custom header passed to a client.
+	AllowedHeaders []string
+	// AllowCredentials allows requests with cookies or HTTP authentication
+	// from the origins listed in AllowedOrigins. Origins only allowed by "*"
+	// are never allowed credentials.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the answer to a preflight
+	// request, if zero browsers use their default.
+	MaxAge time.Duration
+}
+
+// WithCORS makes the server answer cross-origin requests from the allowed
+// origins, including preflight OPTIONS requests. The X-Server-Version header
+// is exposed so the generated clients can detect version mismatches. If the
+// server has WebSocket support, connections are accepted from the allowed
+// origins too, besides the origin of the server.
+func WithCORS(cors CORS) ServerOption {
+	return func(r *Server) {
+		r.cors = &cors
+	}
+}
+
+// allowOrigin reports whether requests from origin are allowed.
+func (c *CORS) allowOrigin(origin string) bool {
+	return c.listsOrigin(origin) || c.listsOrigin("*")
+}
+
+// listsOrigin reports whether origin is one of the allowed origins.
+func (c *CORS) listsOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkOrigin is the origin check of WebSocket connections, connections from
+// the same origin as the server are accepted like without WithCORS.
+func (c *CORS) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+
+	if origin == "" {
+		return true
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return c.allowOrigin(origin)
+}
+
+// serveCORS sets the CORS headers of a response. It returns true if the
+// request is a preflight request, which is answered.
+func (c *CORS) serveCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	w.Header().Add("Vary", "Origin")
+
+	if origin == "" || !c.allowOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		return preflight
+	}
+
+	h := w.Header()
+
+	switch {
+	case c.AllowCredentials && c.listsOrigin(origin):
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+	case c.listsOrigin("*"):
+		// Reflecting any origin with credentials would let any site make
+		// calls as the user.
+		h.Set("Access-Control-Allow-Origin", "*")
+	default:
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if !preflight {
+		h.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		return false
+	}
+
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", "GET, POST")
+	h.Set("Access-Control-Allow-Headers", strings.Join(append(append([]string(nil), corsHeaders...), c.AllowedHeaders...), ", "))
+
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
@@ -0,0 +1,43 @@
+package turborpc
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+)
+
+var errCrossSiteRequest = errors.New("possible cross-site request: Content-Type must not be a form or plain text type")
+
+// WithCSRFProtection protects the server against cross-site request forgery,
+// which matters when calls are authenticated with cookies. POST requests are
+// rejected with status code 403 unless their Content-Type is one that HTML
+// forms cannot send, like application/json, or they have an X-Requested-With
+// header. Browsers only send such requests cross-origin after a CORS
+// preflight, see WithCORS. The generated clients always send a Content-Type.
+// GET requests to queries are not checked, as queries must not change state.
+func WithCSRFProtection() ServerOption {
+	return func(r *Server) {
+		r.csrfProtection = true
+	}
+}
+
+// isSimpleRequest reports whether a request could have been sent cross-site
+// by an HTML form or a simple fetch without a CORS preflight.
+func isSimpleRequest(r *http.Request) bool {
+	if r.Header.Get("X-Requested-With") != "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if err != nil {
+		return true
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data", "text/plain":
+		return true
+	default:
+		return false
+	}
+}
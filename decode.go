@@ -0,0 +1,269 @@
+package turborpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CodeMalformedInput is the error code of inputs that cannot be decoded, its
+// details are a FieldError with the path of the offending field, if known.
+const CodeMalformedInput = "malformed_input"
+
+var (
+	errReadingInput = errors.New("reading input")
+	errTrailingData = errors.New("trailing data after input")
+)
+
+var typeOfUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// WithStrictDecoding makes the server reject method inputs with fields that
+// are not in the input type. It can be overridden per method with
+// WithMethodStrictDecoding.
+func WithStrictDecoding() ServerOption {
+	return func(r *Server) {
+		r.strictDecoding = true
+	}
+}
+
+// WithMethodStrictDecoding sets whether the method rejects inputs with
+// fields that are not in the input type, see WithStrictDecoding.
+func WithMethodStrictDecoding(strict bool) MethodOption {
+	return func(m *method) {
+		m.strictDecoding = strict
+	}
+}
+
+// decodeJSON decodes a single JSON value read from r into v, failing on
+// anything but whitespace after the value. If strict is set it also fails on
+// unknown fields.
+func decodeJSON(r io.Reader, v any, strict bool) error {
+	dec := json.NewDecoder(r)
+
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return errNoInput
+		}
+
+		return err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		if errors.Is(err, errReadingInput) {
+			return err
+		}
+
+		return errTrailingData
+	}
+
+	return nil
+}
+
+// inputReader wraps errors reading an input in errReadingInput, so they can
+// be told apart from errors in the input itself.
+type inputReader struct {
+	r io.Reader
+}
+
+func (ir inputReader) Read(p []byte) (int, error) {
+	n, err := ir.r.Read(p)
+
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", errReadingInput, err)
+	}
+
+	return n, err
+}
+
+// inputError returns the error for an input that could not be decoded. The
+// details are the path of the offending field if it can be found, unknown is
+// the path of the first unknown field in the input, see unknownFieldScanner.
+func inputError(unknown []string, err error) error {
+	var field string
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &typeErr):
+		field = typeErr.Field
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ = strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+
+		if len(unknown) > 0 && unknown[len(unknown)-1] == field {
+			field = strings.Join(unknown, ".")
+		}
+	}
+
+	return &RPCError{
+		Code:    CodeMalformedInput,
+		Status:  http.StatusBadRequest,
+		Message: fmt.Errorf("%w: %w", errDecodingInput, err).Error(),
+		Details: FieldError{Field: field, Message: err.Error()},
+	}
+}
+
+// An unknownFieldScanner finds the path of the first unknown field of a JSON
+// input as it is written to it, so strict decoding can report the path
+// without keeping a copy of the input.
+type unknownFieldScanner struct {
+	w       *io.PipeWriter
+	done    chan struct{}
+	unknown []string
+}
+
+// scanUnknownField returns a scanner for an input of type typ. Its path
+// method must be called once the input has been written.
+func scanUnknownField(typ reflect.Type) *unknownFieldScanner {
+	r, w := io.Pipe()
+	s := &unknownFieldScanner{w: w, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+
+		s.unknown, _ = unknownField(json.NewDecoder(r), typ, nil)
+
+		// Read the rest so writes do not block.
+		io.Copy(io.Discard, r)
+	}()
+
+	return s
+}
+
+func (s *unknownFieldScanner) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// path returns the path of the first unknown field written, or nil if there
+// is none. It may be called more than once.
+func (s *unknownFieldScanner) path() []string {
+	s.w.Close()
+	<-s.done
+
+	return s.unknown
+}
+
+// unknownField reads a JSON value from dec and returns the path to the first
+// key that has no matching struct field in typ. Keys are visited in the order
+// of the input, as encoding/json reports the first unknown field it reads. A
+// nil typ accepts any value.
+func unknownField(dec *json.Decoder, typ reflect.Type, path []string) ([]string, bool) {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ != nil && reflect.PointerTo(typ).Implements(typeOfUnmarshaler) {
+		typ = nil
+	}
+
+	tok, err := dec.Token()
+
+	if err != nil {
+		return nil, false
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			tok, err := dec.Token()
+
+			if err != nil {
+				return nil, false
+			}
+
+			key, _ := tok.(string)
+
+			var elem reflect.Type
+
+			switch {
+			case typ == nil:
+			case typ.Kind() == reflect.Struct:
+				f, ok := jsonField(typ, key)
+
+				if !ok {
+					return append(path, key), true
+				}
+
+				elem = f.Type
+			case typ.Kind() == reflect.Map:
+				elem = typ.Elem()
+			}
+
+			if p, ok := unknownField(dec, elem, append(path, key)); ok {
+				return p, true
+			}
+		}
+	case json.Delim('['):
+		var elem reflect.Type
+
+		if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			elem = typ.Elem()
+		}
+
+		for i := 0; dec.More(); i++ {
+			if p, ok := unknownField(dec, elem, append(path, strconv.Itoa(i))); ok {
+				return p, true
+			}
+		}
+	default:
+		return nil, false
+	}
+
+	// The closing delimiter.
+	dec.Token()
+
+	return nil, false
+}
+
+// jsonField finds the struct field encoding/json decodes the key into,
+// including fields of embedded structs. Like encoding/json the key is matched
+// case-insensitively.
+func jsonField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				if ef, ok := jsonField(ft, key); ok {
+					return ef, true
+				}
+
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,198 @@
+package turborpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// Error codes used by the server for errors that are not an RPCError.
+const (
+	CodeBadRequest = "bad_request"
+	CodeNotFound   = "not_found"
+	CodeCanceled   = "canceled"
+	CodeInternal   = "internal"
+	CodeTimeout    = "timeout"
+)
+
+// builtinErrorCodes are the error codes the server can respond with on its
+// own and the types of their details.
+var builtinErrorCodes = map[string]reflect.Type{
+	CodeBadRequest:           nil,
+	CodeNotFound:             nil,
+	CodeCanceled:             nil,
+	CodeInternal:             nil,
+	CodeTimeout:              nil,
+	CodePayloadTooLarge:      nil,
+	CodeUnsupportedMediaType: nil,
+	CodeUnavailable:          nil,
+	CodeUnauthenticated:      nil,
+	CodeForbidden:            nil,
+	CodeInvalidInput:         reflect.TypeOf(ValidationError{}),
+	CodeMalformedInput:       reflect.TypeOf(FieldError{}),
+	CodeRateLimited:          reflect.TypeOf(RateLimitError{}),
+}
+
+// statusClientClosedRequest is the non-standard status code used when the
+// client cancels a request, it is the same as nginx uses.
+const statusClientClosedRequest = 499
+
+// An HTTPStatusError is an error that chooses the HTTP status code of the
+// response when it is returned by a method. Errors that do not implement
+// HTTPStatusError are answered with status code 500.
+type HTTPStatusError interface {
+	error
+	HTTPStatus() int
+}
+
+// An RPCError is an error that is sent to the client as is. A method can
+// return an RPCError, or an error wrapping one, to give the client a machine
+// readable code, an HTTP status and optional details that are marshaled with
+// encoding/json. Generated clients expose the code, status and details on
+// their own RPCError so callers can switch on the code, see WithErrorCode.
+type RPCError struct {
+	// Code is a machine readable code, e.g. "out_of_stock".
+	Code string
+	// Status is the HTTP status code of the response, if it is zero the
+	// response has status code 400.
+	Status int
+	// Message is a human readable message.
+	Message string
+	// Details is any additional data about the error.
+	Details any
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// HTTPStatus implements HTTPStatusError.
+func (e *RPCError) HTTPStatus() int {
+	if e.Status == 0 {
+		return http.StatusBadRequest
+	}
+
+	return e.Status
+}
+
+// WithErrorCode declares an error code that methods on the server return as
+// an RPCError. The generated TypeScript client includes declared codes in the
+// RPCErrorCode type, and details, if not nil, is a value of the type of the
+// details sent with the code.
+func WithErrorCode(code string, details any) ServerOption {
+	return func(r *Server) {
+		r.errorCodes[code] = reflect.TypeOf(details)
+	}
+}
+
+// errorCode returns the default error code for an HTTP status code.
+func errorCode(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case status == http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status == statusClientClosedRequest:
+		return CodeCanceled
+	case status == http.StatusGatewayTimeout:
+		return CodeTimeout
+	case status == http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case status >= 500:
+		return CodeInternal
+	default:
+		return CodeBadRequest
+	}
+}
+
+// newErrorResponse returns the response sent to the client for an error. If
+// the error is an RPCError its code, status and details are used.
+func newErrorResponse(code int, err error) errorResponse {
+	if err == nil {
+		err = ErrMethodErrored
+	}
+
+	var rpcErr *RPCError
+
+	if !errors.As(err, &rpcErr) {
+		return errorResponse{
+			Status:  code,
+			Code:    errorCode(code),
+			Message: err.Error(),
+		}
+	}
+
+	resp := errorResponse{
+		Status:  rpcErr.HTTPStatus(),
+		Code:    rpcErr.Code,
+		Message: err.Error(),
+		Details: rpcErr.Details,
+	}
+
+	if resp.Code == "" {
+		resp.Code = errorCode(resp.Status)
+	}
+
+	return resp
+}
+
+// methodErrorStatus returns the HTTP status code for an error returned by a
+// method.
+func methodErrorStatus(err error) int {
+	var statusErr HTTPStatusError
+
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.HTTPStatus()
+	case isMaxBytesError(err):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errDecompressing):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorMetadata metadata describing an error code.
+type errorMetadata struct {
+	Code    string
+	Details reflect.Type
+}
+
+// errorsMetadata returns the builtin and declared error codes sorted by code.
+func (rpc *Server) errorsMetadata() []errorMetadata {
+	codes := make(map[string]reflect.Type)
+
+	for code, details := range builtinErrorCodes {
+		codes[code] = details
+	}
+
+	for code, details := range rpc.errorCodes {
+		codes[code] = details
+	}
+
+	var es []errorMetadata
+	for code, details := range codes {
+		es = append(es, errorMetadata{Code: code, Details: details})
+	}
+
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].Code < es[j].Code
+	})
+
+	return es
+}
@@ -2,4 +2,14 @@ module github.com/turborpc/turborpc
 
 go 1.20
 
-require github.com/olahol/tsreflect v0.1.2
+require (
+	github.com/fxamacker/cbor/v2 v2.9.2
+	github.com/gorilla/websocket v1.5.3
+	github.com/olahol/tsreflect v0.1.2
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+)
@@ -0,0 +1,318 @@
+package turborpc
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	_ "embed"
+)
+
+//go:embed go.tmpl
+var goTemplateText string
+
+var errGoClient = errors.New("cannot generate Go client")
+
+type goClient struct {
+	pkg string
+}
+
+func newGoClient(pkg string) goClient {
+	return goClient{pkg: pkg}
+}
+
+func (c goClient) GenerateClient(metadata serverMetadata) sourceClient {
+	src, err := c.generate(metadata)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return sourceClient{
+		ContentType: "text/x-go",
+		SourceCode:  src,
+	}
+}
+
+// generate returns the formatted source code of the client.
+func (c goClient) generate(metadata serverMetadata) (string, error) {
+	if err := checkGoClientNames(metadata); err != nil {
+		return "", err
+	}
+
+	g := newGoTypes()
+
+	for _, s := range metadata.Services {
+		for _, m := range s.Methods {
+			if m.Stream {
+				continue
+			}
+
+			for _, typ := range []reflect.Type{m.Input, m.Output} {
+				if typ != nil {
+					g.typeOf(typ)
+				}
+			}
+		}
+	}
+
+	if g.err != nil {
+		return "", g.err
+	}
+
+	funcs := template.FuncMap{
+		"typeOf": g.typeOf,
+		"isVoid": isVoid,
+	}
+
+	tmpl := template.Must(template.New("").Funcs(funcs).Parse(goTemplateText))
+
+	var sb strings.Builder
+	_ = tmpl.Execute(&sb, map[string]any{
+		"Package":  c.pkg,
+		"Imports":  g.sortedImports(),
+		"Metadata": metadata,
+	})
+
+	src, err := format.Source([]byte(sb.String()))
+
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errGoClient, err)
+	}
+
+	return string(src), nil
+}
+
+// checkGoClientNames checks that the server and its services can be declared
+// in a Go client, i.e. their names are exported identifiers that do not
+// collide with each other or with Version, New and the Client field.
+func checkGoClientNames(metadata serverMetadata) error {
+	declared := map[string]bool{"Version": true, "New": true, "Client": true}
+
+	declare := func(name string) error {
+		if !token.IsIdentifier(name) || !token.IsExported(name) {
+			return fmt.Errorf("%w: %q is not an exported Go identifier", errGoClient, name)
+		}
+
+		if declared[name] {
+			return fmt.Errorf("%w: %q is declared twice", errGoClient, name)
+		}
+
+		declared[name] = true
+
+		return nil
+	}
+
+	if err := declare(metadata.Name); err != nil {
+		return err
+	}
+
+	for _, s := range metadata.Services {
+		if err := declare(s.Name); err != nil {
+			return err
+		}
+
+		if err := declare("New" + s.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GoClient returns source code for a Go client in the package pkg. Each
+// service is a struct with a method for each of the service's methods, which
+// calls it with Call, and the struct RPC has a field for each service. The
+// generated package imports the packages of the input and output types of the
+// methods, so they must be exported and importable, i.e. not in package main,
+// and must not import the generated package. Streaming methods are not
+// included. GoClient panics if the client cannot be generated, e.g. when a
+// service name is not an exported Go identifier or collides with Client,
+// Version or New, see WriteGoClient.
+func (rpc *Server) GoClient(pkg string) string {
+	return rpc.clientSourceCode(newGoClient(pkg))
+}
+
+// WriteGoClient writes a Go client in the package pkg to a file, see GoClient.
+// It returns an error if the client cannot be generated.
+func (rpc *Server) WriteGoClient(filePath, pkg string) error {
+	src, err := newGoClient(pkg).generate(rpc.metadata())
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, []byte(src), 0600)
+}
+
+// MustWriteGoClient generates a Go client in the package pkg and writes it to the specified file path.
+// If an error occurs during the generation or writing process, it will panic.
+func (rpc *Server) MustWriteGoClient(filePath, pkg string) {
+	if err := rpc.WriteGoClient(filePath, pkg); err != nil {
+		panic(err)
+	}
+}
+
+// goQualifiedName matches the package qualified names in the type arguments
+// of the name of an instantiated generic type, e.g. "example.com/pkg.Item" in
+// "List[example.com/pkg.Item]".
+var goQualifiedName = regexp.MustCompile(`[\w./-]+\.\w+`)
+
+type goImport struct {
+	Name string
+	Path string
+}
+
+// goTypes names Go types in generated code and keeps track of the packages
+// that need to be imported.
+type goTypes struct {
+	imports map[string]string
+	names   map[string]bool
+	err     error
+}
+
+func newGoTypes() *goTypes {
+	self := reflect.TypeOf(Server{}).PkgPath()
+
+	return &goTypes{
+		imports: map[string]string{self: "turborpc"},
+		names:   map[string]bool{"context": true, "turborpc": true},
+	}
+}
+
+// importName returns the name a package is imported as.
+func (g *goTypes) importName(pkgPath string) string {
+	if name, ok := g.imports[pkgPath]; ok {
+		return name
+	}
+
+	base := strings.Map(func(r rune) rune {
+		if r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+
+		return '_'
+	}, path.Base(pkgPath))
+
+	if base == "" || '0' <= base[0] && base[0] <= '9' || token.IsKeyword(base) {
+		base = "_" + base
+	}
+
+	name := base
+	for i := 2; g.names[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+
+	g.imports[pkgPath] = name
+	g.names[name] = true
+
+	return name
+}
+
+// qualify replaces the package path of a qualified name with the name the
+// package is imported as.
+func (g *goTypes) qualify(s string) string {
+	i := strings.LastIndexByte(s, '.')
+
+	g.check(s[:i], s[i+1:])
+
+	return g.importName(s[:i]) + s[i:]
+}
+
+// check records an error if a named type cannot be referred to from another
+// package, because it is unexported or in package main.
+func (g *goTypes) check(pkgPath, name string) {
+	if g.err != nil {
+		return
+	}
+
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+
+	switch {
+	case pkgPath == "main":
+		g.err = fmt.Errorf("%w: type %s is in package main", errGoClient, name)
+	case !token.IsExported(name):
+		g.err = fmt.Errorf("%w: type %s.%s is unexported", errGoClient, pkgPath, name)
+	}
+}
+
+// typeOf returns the Go source of a type.
+func (g *goTypes) typeOf(t reflect.Type) string {
+	if name := t.Name(); name != "" {
+		if t.PkgPath() == "" {
+			return name
+		}
+
+		g.check(t.PkgPath(), name)
+
+		if i := strings.IndexByte(name, '['); i >= 0 {
+			name = name[:i] + goQualifiedName.ReplaceAllStringFunc(name[i:], g.qualify)
+		}
+
+		return g.importName(t.PkgPath()) + "." + name
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + g.typeOf(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeOf(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), g.typeOf(t.Elem()))
+	case reflect.Map:
+		return "map[" + g.typeOf(t.Key()) + "]" + g.typeOf(t.Elem())
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any"
+		}
+	case reflect.Struct:
+		var fields []string
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			field := g.typeOf(f.Type)
+
+			if !f.Anonymous {
+				field = f.Name + " " + field
+			}
+
+			if f.Tag != "" && !strings.Contains(string(f.Tag), "`") {
+				field += " `" + string(f.Tag) + "`"
+			} else if f.Tag != "" {
+				field += " " + strconv.Quote(string(f.Tag))
+			}
+
+			fields = append(fields, field)
+		}
+
+		return "struct{ " + strings.Join(fields, "; ") + " }"
+	}
+
+	return t.String()
+}
+
+// sortedImports returns the imported packages sorted by path.
+func (g *goTypes) sortedImports() []goImport {
+	var imports []goImport
+
+	for pkgPath, name := range g.imports {
+		imports = append(imports, goImport{Name: name, Path: pkgPath})
+	}
+
+	sort.Slice(imports, func(i, j int) bool {
+		return imports[i].Path < imports[j].Path
+	})
+
+	return imports
+}
@@ -0,0 +1,53 @@
+package turborpc
+
+import "context"
+
+// CallInfo describes the method call an Interceptor is intercepting.
+type CallInfo struct {
+	Service string
+	Method  string
+	// Stream is true if the method is a streaming method, the output of a
+	// streaming method is always nil.
+	Stream bool
+}
+
+// An Invoker calls a service method with a decoded input and returns its
+// output. For methods without an input the input is nil, and for methods
+// without an output the returned output is ignored.
+type Invoker func(ctx context.Context, input any) (output any, err error)
+
+// An Interceptor intercepts a method call. The input is the decoded input of
+// the method and next calls the next interceptor in the chain, or the method
+// itself if it is the last one. An interceptor that passes a different input
+// to next must pass a value of the method's input type.
+type Interceptor func(ctx context.Context, info CallInfo, input any, next Invoker) (output any, err error)
+
+// WithInterceptor adds an interceptor to every method call on the server.
+// Interceptors are called in the order they are added, and server
+// interceptors are called before service interceptors. Inputs are validated
+// after the last interceptor, see Validator.
+func WithInterceptor(interceptor Interceptor) ServerOption {
+	return func(r *Server) {
+		r.interceptors = append(r.interceptors, interceptor)
+	}
+}
+
+// WithServiceInterceptor adds an interceptor to every method call on the
+// service.
+func WithServiceInterceptor(interceptor Interceptor) ServiceOption {
+	return func(s *service) {
+		s.interceptors = append(s.interceptors, interceptor)
+	}
+}
+
+func chainInterceptors(interceptors []Interceptor, info CallInfo, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+
+		invoker = func(ctx context.Context, input any) (any, error) {
+			return interceptor(ctx, info, input, next)
+		}
+	}
+
+	return invoker
+}
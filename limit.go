@@ -0,0 +1,58 @@
+package turborpc
+
+import (
+	"errors"
+	"net/http"
+)
+
+// CodePayloadTooLarge is the error code of requests with a body larger than
+// the maximum request size.
+const CodePayloadTooLarge = "payload_too_large"
+
+// WithMaxRequestBytes limits the size of request bodies to n bytes. Method
+// inputs are decoded as they are read and requests with larger bodies are
+// answered with status code 413. The limit applies to every method input,
+// including the inputs of calls in a batch or over a WebSocket, and to batch
+// requests as a whole. It can be overridden per method with
+// WithMethodMaxRequestBytes. By default there is no limit.
+func WithMaxRequestBytes(n int64) ServerOption {
+	return func(r *Server) {
+		r.maxRequestBytes = n
+	}
+}
+
+// WithMethodMaxRequestBytes limits the size of the method's input to n
+// bytes, zero means no limit, see WithMaxRequestBytes.
+func WithMethodMaxRequestBytes(n int64) MethodOption {
+	return func(m *method) {
+		m.maxRequestBytes = n
+	}
+}
+
+// maxInputBytes returns the largest input any method on the server accepts,
+// counting methods without a limit as accepting unlimited bytes.
+func (rpc *Server) maxInputBytes(unlimited int64) int64 {
+	max := rpc.maxRequestBytes
+
+	for _, s := range rpc.services {
+		for _, m := range s.methods {
+			n := m.maxRequestBytes
+
+			if n <= 0 {
+				n = unlimited
+			}
+
+			if n > max {
+				max = n
+			}
+		}
+	}
+
+	return max
+}
+
+func isMaxBytesError(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+
+	return errors.As(err, &maxBytesErr)
+}
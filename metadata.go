@@ -3,17 +3,24 @@ package turborpc
 import (
 	"crypto/sha1"
 	"fmt"
+	"path"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 const defaultRPCClassName = "RPC"
 
-// methodMetadata metadata describing a service method.
+// methodMetadata metadata describing a service method. For streaming methods
+// Output is the type of the streamed values.
 type methodMetadata struct {
 	Name   string
 	Input  reflect.Type
 	Output reflect.Type
+	Stream bool
+	Query  bool
 }
 
 // serviceMetadata metadata describing a server service.
@@ -25,12 +32,15 @@ type serviceMetadata struct {
 
 // serverMetadata metadata describing a server.
 type serverMetadata struct {
-	Name     string
-	Services []serviceMetadata
-	Version  string
+	Name         string
+	Services     []serviceMetadata
+	Errors       []errorMetadata
+	Version      string
+	MaxBatchSize int
 }
 
-// types get all method types, both input and output.
+// types get all method types, both input and output, and the types of error
+// details.
 func (i serverMetadata) types() []reflect.Type {
 	var typs []reflect.Type
 
@@ -41,6 +51,12 @@ func (i serverMetadata) types() []reflect.Type {
 		}
 	}
 
+	for _, e := range i.Errors {
+		if e.Details != nil {
+			typs = append(typs, e.Details)
+		}
+	}
+
 	return typs
 }
 
@@ -49,6 +65,8 @@ func (m *method) metadata() methodMetadata {
 		Name:   m.name,
 		Input:  m.input,
 		Output: m.output,
+		Stream: m.stream != nil,
+		Query:  m.isQuery(),
 	}
 }
 
@@ -81,9 +99,11 @@ func (rpc *Server) metadata() serverMetadata {
 	})
 
 	return serverMetadata{
-		Name:     defaultRPCClassName,
-		Services: ss,
-		Version:  rpc.version,
+		Name:         defaultRPCClassName,
+		Services:     ss,
+		Errors:       rpc.errorsMetadata(),
+		Version:      rpc.version,
+		MaxBatchSize: rpc.maxBatchSize,
 	}
 }
 
@@ -99,6 +119,10 @@ func calculateMethodVersion(md methodMetadata) string {
 		hash.Write([]byte(md.Output.String()))
 	}
 
+	if md.Stream {
+		hash.Write([]byte("stream"))
+	}
+
 	return fmt.Sprintf("%x", hash.Sum(nil))
 }
 
@@ -122,3 +146,100 @@ func calculateServerVersion(md serverMetadata) string {
 
 	return fmt.Sprintf("%x", hash.Sum(nil))
 }
+
+// typeName returns a name for a named type that is not in used, for the
+// declarations of generated code. The package paths in the type arguments of
+// generic types are dropped, e.g. "Page[example.com/pkg.Item]" becomes
+// "PageItem", and if the name is used the name of the type's package is
+// prepended.
+func typeName(t reflect.Type, used map[string]bool) string {
+	base := t.Name()
+
+	if i := strings.IndexByte(base, '['); i >= 0 {
+		base = base[:i] + goQualifiedName.ReplaceAllStringFunc(base[i:], func(s string) string {
+			return s[strings.LastIndexByte(s, '.')+1:]
+		})
+	}
+
+	base = strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		return -1
+	}, base)
+
+	if used[base] {
+		pkg := path.Base(t.PkgPath())
+		base = strings.ToUpper(pkg[:1]) + pkg[1:] + base
+	}
+
+	name := base
+	for i := 2; used[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+
+	used[name] = true
+
+	return name
+}
+
+// marshaledField is a field of a struct as it is marshaled by encoding/json.
+type marshaledField struct {
+	name      string
+	typ       reflect.Type
+	omitempty bool
+	asString  bool
+}
+
+// marshaledFields returns the fields of a struct type as they are marshaled by
+// encoding/json, embedded structs without a name have their fields inlined.
+func marshaledFields(t reflect.Type) []marshaledField {
+	var fields []marshaledField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		opts = "," + opts + ","
+
+		ft := f.Type
+
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && !ft.Implements(typeOfJSONMarshaler) {
+			fields = append(fields, marshaledFields(ft)...)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		kind := f.Type.Kind()
+
+		fields = append(fields, marshaledField{
+			name: name,
+			typ:  f.Type,
+			// Structs are never empty.
+			omitempty: strings.Contains(opts, ",omitempty,") && kind != reflect.Struct,
+			// The string option only applies to scalars.
+			asString: strings.Contains(opts, ",string,") && (kind == reflect.Bool || kind == reflect.String ||
+				reflect.Int <= kind && kind <= reflect.Float64),
+		})
+	}
+
+	return fields
+}
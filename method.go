@@ -1,11 +1,14 @@
 package turborpc
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"reflect"
+	"time"
 )
 
 var (
@@ -15,21 +18,39 @@ var (
 )
 
 type method struct {
-	name   string
-	fn     reflect.Value
-	input  reflect.Type
-	output reflect.Type
+	name            string
+	fn              reflect.Value
+	input           reflect.Type
+	output          reflect.Type
+	stream          reflect.Type
+	strictDecoding  bool
+	maxRequestBytes int64
+	timeout         time.Duration
+	limiter         *limiter
+	limiters        []*limiter
+	rateLimiter     *rateLimiter
+	policies        []Policy
+	query           bool
 }
 
 func newMethod(m reflect.Method, fn reflect.Value) *method {
-	var input, output reflect.Type
+	var input, output, stream reflect.Type
 
-	if m.Type.NumIn() == 3 {
-		input = m.Type.In(2)
-	}
+	if isStreamMethod(m) {
+		if m.Type.NumIn() == 4 {
+			input = m.Type.In(2)
+		}
+
+		stream = m.Type.In(m.Type.NumIn() - 1)
+		output = streamElemType(stream)
+	} else {
+		if m.Type.NumIn() == 3 {
+			input = m.Type.In(2)
+		}
 
-	if m.Type.NumOut() == 2 {
-		output = m.Type.Out(0)
+		if m.Type.NumOut() == 2 {
+			output = m.Type.Out(0)
+		}
 	}
 
 	return &method{
@@ -37,14 +58,14 @@ func newMethod(m reflect.Method, fn reflect.Value) *method {
 		fn:     fn,
 		input:  input,
 		output: output,
+		stream: stream,
 	}
 }
 
-func (m *method) decodeInput(input []byte) (argv reflect.Value, err error) {
-	if len(input) == 0 {
-		return argv, errNoInput
-	}
-
+// decodeInput decodes the input read from r with codec. Errors reading r are
+// returned as is, other errors are returned as an RPCError with code
+// CodeMalformedInput.
+func (m *method) decodeInput(r io.Reader, codec Codec) (argv reflect.Value, err error) {
 	argIsValue := false
 	if m.input.Kind() == reflect.Pointer {
 		argv = reflect.New(m.input.Elem())
@@ -53,8 +74,29 @@ func (m *method) decodeInput(input []byte) (argv reflect.Value, err error) {
 		argIsValue = true
 	}
 
-	if err := json.Unmarshal(input, argv.Interface()); err != nil {
-		return argv, err
+	r = inputReader{r}
+
+	// Strict JSON decoding scans the input as it is read to find the path of
+	// an unknown field. The scanner is stopped when decodeInput returns.
+	var scanner *unknownFieldScanner
+	if _, ok := codec.(jsonCodec); ok && m.strictDecoding {
+		scanner = scanUnknownField(m.input)
+		defer scanner.path()
+
+		r = io.TeeReader(r, scanner)
+	}
+
+	if err := codec.Decode(r, argv.Interface(), m.strictDecoding); err != nil {
+		if errors.Is(err, errReadingInput) {
+			return argv, err
+		}
+
+		var unknown []string
+		if scanner != nil {
+			unknown = scanner.path()
+		}
+
+		return argv, inputError(unknown, err)
 	}
 
 	if argIsValue {
@@ -64,23 +106,45 @@ func (m *method) decodeInput(input []byte) (argv reflect.Value, err error) {
 	return argv, nil
 }
 
-func (m *method) invoke(ctx context.Context, bs []byte) ([]byte, error) {
-	var outputs []reflect.Value
+// limitInput limits the input read from r to the maximum input size of the
+// method, w is passed to http.MaxBytesReader and may be nil.
+func (m *method) limitInput(w http.ResponseWriter, r io.ReadCloser) io.Reader {
+	if m.maxRequestBytes <= 0 {
+		return r
+	}
 
-	if m.input == nil {
-		outputs = m.fn.Call([]reflect.Value{reflect.ValueOf(ctx)})
-	} else {
-		input, err := m.decodeInput(bs)
+	return http.MaxBytesReader(w, r, m.maxRequestBytes)
+}
 
-		if err != nil {
-			return nil, fmt.Errorf("%w: %w", errDecodingInput, err)
+// bytesInput returns a reader for an input that has already been read, like
+// the input of a call in a batch.
+func (m *method) bytesInput(input []byte) io.Reader {
+	return m.limitInput(nil, io.NopCloser(bytes.NewReader(input)))
+}
+
+// call calls the method with an already decoded input. Values sent by a
+// streaming method are passed to send.
+func (m *method) call(ctx context.Context, input any, send func(v any) error) (any, error) {
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+
+	if m.input != nil {
+		argv := reflect.ValueOf(input)
+
+		if !argv.IsValid() {
+			argv = reflect.Zero(m.input)
 		}
 
-		outputs = m.fn.Call([]reflect.Value{reflect.ValueOf(ctx), input})
+		args = append(args, argv)
 	}
 
+	if m.stream != nil {
+		args = append(args, newStream(m.stream, send))
+	}
+
+	outputs := m.fn.Call(args)
+
 	var resp, errv reflect.Value
-	if m.output == nil {
+	if m.output == nil || m.stream != nil {
 		errv = outputs[0]
 	} else {
 		resp = outputs[0]
@@ -95,7 +159,74 @@ func (m *method) invoke(ctx context.Context, bs []byte) ([]byte, error) {
 		return nil, nil
 	}
 
-	buf, err := json.Marshal(resp.Interface())
+	return resp.Interface(), nil
+}
+
+// intercept decodes the input and calls the method through the interceptors,
+// with the method's timeout applied to the context. The call is authorized,
+// checked against its rate limit and waits for its concurrency limits before
+// the input is decoded.
+func (m *method) intercept(ctx context.Context, info CallInfo, interceptors []Interceptor, r io.Reader, codec Codec, send func(v any) error) (any, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if err := m.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	if m.rateLimiter != nil {
+		if err := m.rateLimiter.allow(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	release, err := acquireLimits(ctx, m.limiters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	var input any
+
+	if m.input != nil {
+		argv, err := m.decodeInput(r, codec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		input = argv.Interface()
+	}
+
+	invoker := func(ctx context.Context, input any) (any, error) {
+		return m.call(ctx, input, send)
+	}
+
+	return chainInterceptors(interceptors, info, invoker)(ctx, input)
+}
+
+// invoke calls the method with an input decoded with codec and returns its
+// output. A panic in the call is returned as an error.
+func (m *method) invoke(ctx context.Context, info CallInfo, interceptors []Interceptor, r io.Reader, codec Codec) (output any, err error) {
+	defer recoverPanic(info, &err)
+
+	output, err = m.intercept(ctx, info, interceptors, r, codec, nil)
+
+	if err != nil || m.output == nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// encodeOutput encodes a response with the output of a call. A panic while
+// encoding, e.g. in a MarshalJSON method, is returned as an error.
+func encodeOutput(codec Codec, info CallInfo, v any) (buf []byte, err error) {
+	defer recoverPanic(info, &err)
+
+	buf, err = codec.Marshal(v)
 
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", errEncodingOutput, err)
@@ -103,3 +234,13 @@ func (m *method) invoke(ctx context.Context, bs []byte) ([]byte, error) {
 
 	return buf, nil
 }
+
+// invokeStream is like invoke but for streaming methods, the values sent by
+// the method are passed to send.
+func (m *method) invokeStream(ctx context.Context, info CallInfo, interceptors []Interceptor, r io.Reader, codec Codec, send func(v any) error) (err error) {
+	defer recoverPanic(info, &err)
+
+	_, err = m.intercept(ctx, info, interceptors, r, codec, send)
+
+	return err
+}
@@ -0,0 +1,246 @@
+package turborpc
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+)
+
+// openAPIVersion is the version of the OpenAPI specification of the
+// generated documents.
+const openAPIVersion = "3.1.0"
+
+type openAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       openAPIInfo                `json:"info"`
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components openAPIComponents          `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIPathItem struct {
+	Get  *openAPIOperation `json:"get,omitempty"`
+	Post *openAPIOperation `json:"post,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Ref         string                      `json:"$ref,omitempty"`
+	Name        string                      `json:"name,omitempty"`
+	In          string                      `json:"in,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Schema      *jsonSchema                 `json:"schema,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Ref         string                      `json:"$ref,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Headers     map[string]*openAPIHeader   `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Ref         string      `json:"$ref,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Schema      *jsonSchema `json:"schema,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas    map[string]*jsonSchema      `json:"schemas"`
+	Responses  map[string]*openAPIResponse `json:"responses"`
+	Parameters map[string]openAPIParameter `json:"parameters"`
+	Headers    map[string]*openAPIHeader   `json:"headers"`
+}
+
+type openAPI struct {
+}
+
+func newOpenAPI() (o openAPI) {
+	return o
+}
+
+func (o openAPI) GenerateClient(metadata serverMetadata) sourceClient {
+	buf, _ := json.MarshalIndent(openAPIDocumentOf(metadata), "", "  ")
+
+	return sourceClient{
+		ContentType: "application/json",
+		SourceCode:  string(buf) + "\n",
+	}
+}
+
+// OpenAPI returns an OpenAPI 3.1 document describing the services of the
+// server as JSON. Each method has a path in the form it is called,
+// "/?service=Service&method=Method", relative to the URL the server is
+// mounted at, with a POST operation and a GET operation for queries. Inputs
+// and outputs are described with JSON Schema components, error responses
+// with the ErrorResponse schema and the details of the error codes, and the
+// version of the document is the version of the server. Streaming methods
+// respond with server-sent events.
+func (rpc *Server) OpenAPI() string {
+	return rpc.clientSourceCode(newOpenAPI())
+}
+
+// WriteOpenAPI writes an OpenAPI document to a file.
+func (rpc *Server) WriteOpenAPI(filePath string) error {
+	return rpc.writeClientSourceCode(newOpenAPI(), filePath)
+}
+
+// MustWriteOpenAPI generates an OpenAPI document and writes it to the specified file path.
+// If an error occurs during the generation or writing process, it will panic.
+func (rpc *Server) MustWriteOpenAPI(filePath string) {
+	if err := rpc.WriteOpenAPI(filePath); err != nil {
+		panic(err)
+	}
+}
+
+// openAPIDocumentOf returns the OpenAPI document of a server.
+func openAPIDocumentOf(metadata serverMetadata) openAPIDocument {
+	g := newJSONSchemas("#/components/schemas/", "ErrorResponse")
+
+	doc := openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   metadata.Name,
+			Version: metadata.Version,
+		},
+		Paths: make(map[string]openAPIPathItem),
+		Components: openAPIComponents{
+			Responses: map[string]*openAPIResponse{
+				"Error": {
+					Description: "The call failed.",
+					Headers:     map[string]*openAPIHeader{"X-Server-Version": {Ref: "#/components/headers/ServerVersion"}},
+					Content:     jsonContent(&jsonSchema{Ref: "#/components/schemas/ErrorResponse"}),
+				},
+			},
+			Parameters: map[string]openAPIParameter{
+				"Timeout": {
+					Name:        timeoutHeader,
+					In:          "header",
+					Description: "The number of milliseconds the server may spend on the call.",
+					Schema:      &jsonSchema{Type: "integer", Format: "int64"},
+				},
+			},
+			Headers: map[string]*openAPIHeader{
+				"ServerVersion": {
+					Description: "The version of the server, it changes when its services change.",
+					Schema:      &jsonSchema{Type: "string"},
+				},
+			},
+		},
+	}
+
+	for _, s := range metadata.Services {
+		for _, m := range s.Methods {
+			var item openAPIPathItem
+
+			item.Post = openAPIOperationOf(g, s, m)
+
+			if m.Query {
+				item.Get = openAPIOperationOf(g, s, m)
+				item.Get.OperationID += ".query"
+				item.Get.RequestBody = nil
+
+				if m.Input != nil {
+					item.Get.Parameters = append(item.Get.Parameters, openAPIParameter{
+						Name:        "input",
+						In:          "query",
+						Description: "The input of the call.",
+						Content:     jsonContent(g.schemaOf(m.Input)),
+					})
+				}
+			}
+
+			doc.Paths["/?service="+url.QueryEscape(s.Name)+"&method="+url.QueryEscape(m.Name)] = item
+		}
+	}
+
+	errResp := g.structOf(reflect.TypeOf(errorResponse{}))
+
+	for _, e := range metadata.Errors {
+		if e.Details == nil {
+			continue
+		}
+
+		errResp.AllOf = append(errResp.AllOf, &jsonSchema{
+			If: &jsonSchema{
+				Properties: jsonProperties{{Name: "code", Schema: &jsonSchema{Const: e.Code}}},
+			},
+			Then: &jsonSchema{
+				Properties: jsonProperties{{Name: "details", Schema: g.schemaOf(e.Details)}},
+			},
+		})
+	}
+
+	g.defs["ErrorResponse"] = errResp
+	doc.Components.Schemas = g.defs
+
+	return doc
+}
+
+// openAPIOperationOf returns the POST operation calling a method.
+func openAPIOperationOf(g *jsonSchemas, s serviceMetadata, m methodMetadata) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: s.Name + "." + m.Name,
+		Tags:        []string{s.Name},
+		Parameters:  []openAPIParameter{{Ref: "#/components/parameters/Timeout"}},
+		Responses: map[string]*openAPIResponse{
+			"default": {Ref: "#/components/responses/Error"},
+		},
+	}
+
+	if m.Input != nil {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  jsonContent(g.schemaOf(m.Input)),
+		}
+	}
+
+	ok := &openAPIResponse{
+		Description: "The output of the call.",
+		Headers:     map[string]*openAPIHeader{"X-Server-Version": {Ref: "#/components/headers/ServerVersion"}},
+	}
+
+	if m.Stream {
+		ok.Description = "Server-sent events. The data of each unnamed event is a streamed value encoded as JSON. " +
+			"The stream ends with an \"end\" event whose data is null, or with an \"error\" event whose data is an ErrorResponse " +
+			"if the call fails after values have been sent. A call that fails before sending a value is answered with an error response."
+		ok.Content = map[string]openAPIMediaType{"text/event-stream": {Schema: &jsonSchema{Type: "string"}}}
+	} else {
+		ok.Content = jsonContent(&jsonSchema{
+			Type:       "object",
+			Properties: jsonProperties{{Name: "output", Schema: g.schemaOf(m.Output)}},
+			Required:   []string{"output"},
+		})
+	}
+
+	op.Responses["200"] = ok
+
+	return op
+}
+
+// jsonContent returns the content of a request or response encoded with
+// JSONCodec.
+func jsonContent(schema *jsonSchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{JSONCodec.ContentType(): {Schema: schema}}
+}
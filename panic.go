@@ -0,0 +1,44 @@
+package turborpc
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// A PanicHandler is called with the service and method names, the recovered
+// value and the stack trace when a method call panics.
+type PanicHandler func(service, method string, v any, stack []byte)
+
+func defaultPanicHandler(service, method string, v any, stack []byte) {
+	fmt.Fprintf(os.Stderr, "TurboRPC ~ %s::%s panicked: %v\n%s", service, method, v, stack)
+}
+
+// WithPanicHandler sets the function that is called when a method call
+// panics. The panic is recovered and the client receives an error response
+// with status code 500. By default panics are printed to standard error, a
+// nil handler disables reporting.
+func WithPanicHandler(handler PanicHandler) ServerOption {
+	return func(r *Server) {
+		r.panicHandler = handler
+	}
+}
+
+// panicError is a panic recovered from a method call.
+type panicError struct {
+	info  CallInfo
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// recoverPanic recovers a panic in a method call and sets err to a
+// panicError, it must be deferred directly.
+func recoverPanic(info CallInfo, err *error) {
+	if v := recover(); v != nil {
+		*err = &panicError{info: info, value: v, stack: debug.Stack()}
+	}
+}
@@ -0,0 +1,100 @@
+package turborpc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var errNotQuery = errors.New("method is not a query")
+
+// WithMethodQuery marks the method as a query, a read-only method that can
+// also be called with a GET request on the form
+//
+//	?service=Service&method=Method&input=<URL encoded JSON input>
+//
+// Responses to GET requests have an ETag header and are answered with status
+// code 304 if it matches the request's If-None-Match header, so they can be
+// cached by browsers and CDNs, see SetCacheControl. The generated clients call
+// queries with GET. It has no effect on streaming methods.
+func WithMethodQuery() MethodOption {
+	return func(m *method) {
+		m.query = true
+	}
+}
+
+// isQuery reports whether the method can be called with GET.
+func (m *method) isQuery() bool {
+	return m.query && m.stream == nil
+}
+
+// serveQuery answers a GET request calling a query, see WithMethodQuery.
+func (rpc *Server) serveQuery(w http.ResponseWriter, r *http.Request, query url.Values) {
+	out := rpc.responseCodec(r, JSONCodec)
+
+	s, m, err := rpc.lookup(query.Get("service"), query.Get("method"))
+
+	if err == nil && !m.isQuery() {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, fmt.Errorf("%w %q", errNotQuery, m.name))
+		return
+	}
+
+	ctx, call := withHTTPCall(r.Context(), r)
+
+	var output any
+	if err == nil {
+		output, err = rpc.call(ctx, s, m, strings.NewReader(query.Get("input")), JSONCodec)
+	}
+
+	var buf []byte
+	if err == nil {
+		buf, err = encodeOutput(out, CallInfo{Service: s.name, Method: m.name}, outputResponse{Output: output})
+	}
+
+	if err != nil {
+		call.writeHeader(w, false)
+		setRetryAfter(w, err)
+		writeErrorResponse(w, out, newErrorResponse(rpc.errorStatus(err)))
+		return
+	}
+
+	call.writeHeader(w, true)
+
+	tag := etag(buf)
+
+	w.Header().Set("ETag", tag)
+	w.Header().Add("Vary", "Accept")
+
+	if etagMatch(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeResponse(w, out, http.StatusOK, rpc.compress(w, r, buf))
+}
+
+// etag returns a weak entity tag for a response body. It is weak as the
+// same tag is used for every content encoding of the body.
+func etag(buf []byte) string {
+	sum := sha256.Sum256(buf)
+
+	return fmt.Sprintf(`W/"%x"`, sum[:16])
+}
+
+// etagMatch reports whether an If-None-Match header matches the entity tag,
+// using the weak comparison.
+func etagMatch(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package turborpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CodeRateLimited is the error code of calls that exceed a rate limit, its
+// details are a RateLimitError.
+const CodeRateLimited = "rate_limited"
+
+// A RateLimitError is sent to the client as the details of an error with
+// code CodeRateLimited and status code 429. RetryAfterMs is the number of
+// milliseconds until the call is allowed again.
+type RateLimitError struct {
+	RetryAfterMs int64 `json:"retryAfterMs"`
+}
+
+func (e RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %dms", e.RetryAfterMs)
+}
+
+// WithRateLimitKey sets the function that returns the key calls are rate
+// limited by, e.g. the authenticated user, see WithMethodRateLimit. For
+// calls in a batch or over a WebSocket the request is the batch request or
+// the request that opened the connection. By default calls are limited by
+// the IP address of the client.
+func WithRateLimitKey(key func(r *http.Request) string) ServerOption {
+	return func(r *Server) {
+		r.rateLimitKey = key
+	}
+}
+
+// WithMethodRateLimit limits the calls to the method to n per duration for
+// each rate limit key, see WithRateLimitKey. The limit is a token bucket
+// holding n tokens that is refilled at a steady rate, so a client may make n
+// calls at once. Calls over the limit are answered with status code 429, a
+// Retry-After header and a RateLimitError. If n or per is zero or less the
+// method is not rate limited.
+func WithMethodRateLimit(n int, per time.Duration) MethodOption {
+	return func(m *method) {
+		if n <= 0 || per <= 0 {
+			m.rateLimiter = nil
+			return
+		}
+
+		m.rateLimiter = &rateLimiter{
+			n:       float64(n),
+			per:     per,
+			buckets: make(map[string]*bucket),
+		}
+	}
+}
+
+// remoteIP returns the IP address of the client of a request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter keeps a token bucket for every rate limit key.
+type rateLimiter struct {
+	n       float64
+	per     time.Duration
+	key     func(r *http.Request) string
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// allow takes a token from the bucket of the call's key, or returns the
+// error for a call over the limit.
+func (l *rateLimiter) allow(ctx context.Context) error {
+	var key string
+
+	if r := RequestFromContext(ctx); r != nil {
+		key = l.key(r)
+	}
+
+	wait, ok := l.take(key, time.Now())
+
+	if ok {
+		return nil
+	}
+
+	details := RateLimitError{RetryAfterMs: int64((wait + time.Millisecond - 1) / time.Millisecond)}
+
+	return &retryError{
+		err: &RPCError{
+			Code:    CodeRateLimited,
+			Status:  http.StatusTooManyRequests,
+			Message: details.Error(),
+			Details: details,
+		},
+		status: http.StatusTooManyRequests,
+		after:  wait,
+	}
+}
+
+// take takes a token from the bucket of key, or returns the time until the
+// bucket has a token.
+func (l *rateLimiter) take(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+
+	if !ok {
+		b = &bucket{tokens: l.n, updated: now}
+		l.buckets[key] = b
+	}
+
+	rate := l.n / float64(l.per)
+
+	b.tokens += float64(now.Sub(b.updated)) * rate
+	b.updated = now
+
+	if b.tokens > l.n {
+		b.tokens = l.n
+	}
+
+	if b.tokens < 1 {
+		return time.Duration(math.Ceil((1 - b.tokens) / rate)), false
+	}
+
+	b.tokens--
+
+	return 0, true
+}
+
+// sweep removes the buckets that have refilled, at most once per period of
+// the limit.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.per {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.per {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.swept = now
+}
@@ -0,0 +1,271 @@
+package turborpc
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+)
+
+// jsonSchemaDialect is the JSON Schema dialect of generated schemas.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// dateSchemaPattern matches a marshaled Date, or an RFC 3339 date as the
+// JavaScript clients send dates.
+var dateSchemaPattern = "^(" + regexp.QuoteMeta(datePrefix+"(") + `)?\d{4}-\d{2}-\d{2}T`
+
+var typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// jsonSchema is a JSON Schema (draft 2020-12), the dialect of OpenAPI 3.1.
+type jsonSchema struct {
+	Schema          string                 `json:"$schema,omitempty"`
+	Ref             string                 `json:"$ref,omitempty"`
+	Description     string                 `json:"description,omitempty"`
+	Type            any                    `json:"type,omitempty"`
+	Format          string                 `json:"format,omitempty"`
+	Pattern         string                 `json:"pattern,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	Const           any                    `json:"const,omitempty"`
+	Minimum         *int                   `json:"minimum,omitempty"`
+	Items           *jsonSchema            `json:"items,omitempty"`
+	MinItems        *int                   `json:"minItems,omitempty"`
+	MaxItems        *int                   `json:"maxItems,omitempty"`
+	Properties      jsonProperties         `json:"properties,omitempty"`
+	Additional      *jsonSchema            `json:"additionalProperties,omitempty"`
+	Required        []string               `json:"required,omitempty"`
+	AnyOf           []*jsonSchema          `json:"anyOf,omitempty"`
+	AllOf           []*jsonSchema          `json:"allOf,omitempty"`
+	If              *jsonSchema            `json:"if,omitempty"`
+	Then            *jsonSchema            `json:"then,omitempty"`
+	Defs            map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+type jsonProperty struct {
+	Name   string
+	Schema *jsonSchema
+}
+
+// jsonProperties are the properties of an object schema, they are marshaled
+// in the order of the fields of the struct.
+type jsonProperties []jsonProperty
+
+func (ps jsonProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, p := range ps {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, _ := json.Marshal(p.Name)
+		schema, err := json.Marshal(p.Schema)
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(schema)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// nullable returns a schema that also allows null.
+func (s *jsonSchema) nullable() *jsonSchema {
+	switch typ := s.Type.(type) {
+	case string:
+		c := *s
+		c.Type = []string{typ, "null"}
+		return &c
+	case []string:
+		return s
+	case nil:
+		if s.Ref == "" {
+			return s
+		}
+	}
+
+	return &jsonSchema{AnyOf: []*jsonSchema{s, {Type: "null"}}}
+}
+
+// jsonSchemas generates the JSON Schemas of Go types as they are marshaled by
+// encoding/json. Named struct types are declared once and referenced with
+// refPrefix followed by their name.
+type jsonSchemas struct {
+	refPrefix string
+	defs      map[string]*jsonSchema
+	names     map[reflect.Type]string
+	used      map[string]bool
+}
+
+func newJSONSchemas(refPrefix string, reserved ...string) *jsonSchemas {
+	used := make(map[string]bool)
+
+	for _, name := range reserved {
+		used[name] = true
+	}
+
+	return &jsonSchemas{
+		refPrefix: refPrefix,
+		defs:      make(map[string]*jsonSchema),
+		names:     make(map[reflect.Type]string),
+		used:      used,
+	}
+}
+
+// schemaOf returns the schema of a type, a nil type has the schema of null.
+func (g *jsonSchemas) schemaOf(t reflect.Type) *jsonSchema {
+	switch {
+	case t == nil:
+		return &jsonSchema{Type: "null"}
+	case t.Kind() == reflect.Pointer:
+		return g.schemaOf(t.Elem()).nullable()
+	case t == typeOfDate:
+		return &jsonSchema{
+			Type:        "string",
+			Pattern:     dateSchemaPattern,
+			Description: "A date marshaled as " + datePrefix + "(RFC 3339 date).",
+		}
+	case t == typeOfTime:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case t.Implements(typeOfJSONMarshaler):
+		// NonNullSlice and NonNullMap are never null.
+		if t.PkgPath() == typeOfDate.PkgPath() && (t.Kind() == reflect.Slice || t.Kind() == reflect.Map) {
+			return g.kindOf(t)
+		}
+
+		return &jsonSchema{}
+	case t.Implements(typeOfTextMarshaler):
+		return &jsonSchema{Type: "string"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return &jsonSchema{Ref: g.refPrefix + g.declare(t)}
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Interface:
+		return g.kindOf(t).nullable()
+	}
+
+	return g.kindOf(t)
+}
+
+// kindOf returns the schema of a type by its kind.
+func (g *jsonSchemas) kindOf(t reflect.Type) *jsonSchema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &jsonSchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &jsonSchema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := 0
+		return &jsonSchema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &jsonSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &jsonSchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string", ContentEncoding: "base64"}
+		}
+
+		return &jsonSchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Array:
+		n := t.Len()
+		return &jsonSchema{Type: "array", Items: g.schemaOf(t.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", Additional: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.structOf(t)
+	default:
+		return &jsonSchema{}
+	}
+}
+
+// structOf returns the object schema of a struct type.
+func (g *jsonSchemas) structOf(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: jsonProperties{}}
+
+	for _, f := range marshaledFields(t) {
+		schema := g.schemaOf(f.typ)
+
+		if f.asString {
+			schema = &jsonSchema{Type: "string"}
+		}
+
+		s.Properties = append(s.Properties, jsonProperty{Name: f.name, Schema: schema})
+
+		if !f.omitempty {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+
+	return s
+}
+
+// declare declares the schema of a named struct type and returns its name.
+func (g *jsonSchemas) declare(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := typeName(t, g.used)
+	g.names[t] = name
+	g.defs[name] = g.structOf(t)
+
+	return name
+}
+
+// document returns a schema of a type that is a document of its own, the
+// named struct types it refers to are declared in $defs.
+func (g *jsonSchemas) document(t reflect.Type) string {
+	root := g.schemaOf(t)
+
+	doc := *root
+	doc.Schema = jsonSchemaDialect
+
+	if len(g.defs) > 0 {
+		doc.Defs = g.defs
+	}
+
+	buf, _ := json.MarshalIndent(&doc, "", "  ")
+
+	return string(buf)
+}
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of the type of v, as it
+// is marshaled by encoding/json and described to the generated clients. Field
+// names come from json tags, fields with omitempty are not required, and
+// pointers, slices and maps may be null. A Date is a string in the form
+// marshaled by Date, or an RFC 3339 date as clients send, and NonNullSlice and
+// NonNullMap are never null. Named struct types are declared in $defs. A nil v
+// has the schema of null.
+func JSONSchema(v any) string {
+	return newJSONSchemas("#/$defs/").document(reflect.TypeOf(v))
+}
+
+// MethodJSONSchema returns the JSON Schemas of the input and the output of a
+// method, see JSONSchema. Methods without an input or output have the schema
+// of null for it, and the output of a streaming method is the schema of the
+// streamed values.
+func (rpc *Server) MethodJSONSchema(service, method string) (input, output string, err error) {
+	_, m, err := rpc.lookup(service, method)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	md := m.metadata()
+
+	return newJSONSchemas("#/$defs/").document(md.Input), newJSONSchemas("#/$defs/").document(md.Output), nil
+}
@@ -0,0 +1,152 @@
+package turborpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+type TestSchemaBase struct {
+	ID int64 `json:"id"`
+}
+
+type TestSchemaNode struct {
+	TestSchemaBase
+	Name     string                        `json:"name"`
+	Note     string                        `json:"note,omitempty"`
+	Count    int                           `json:"count,string"`
+	Created  Date                          `json:"created"`
+	Updated  *Date                         `json:"updated,omitempty"`
+	Item     TestItem                      `json:"item,omitempty"`
+	Parent   *TestSchemaNode               `json:"parent"`
+	Children NonNullSlice[*TestSchemaNode] `json:"children"`
+	Tags     []string                      `json:"tags"`
+	Labels   NonNullMap[string, uint8]     `json:"labels"`
+	Scores   map[string]float64            `json:"scores"`
+	Data     []byte                        `json:"data"`
+	Point    [2]float32                    `json:"point"`
+	IP       net.IP                        `json:"ip"`
+	At       time.Time                     `json:"at"`
+	Extra    any                           `json:"extra"`
+	Ignored  string                        `json:"-"`
+	Plain    bool
+	hidden   bool
+}
+
+func assertJSONEqual(t *testing.T, expected string, actual string) bool {
+	t.Helper()
+
+	var e, a bytes.Buffer
+
+	if err := json.Compact(&e, []byte(expected)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Compact(&a, []byte(actual)); err != nil {
+		t.Fatal(err)
+	}
+
+	return assertEqual(t, e.String(), a.String())
+}
+
+func TestJSONSchema(t *testing.T) {
+	t.Run("scalars", func(t *testing.T) {
+		for _, tt := range []struct {
+			v    any
+			want string
+		}{
+			{nil, `{"type": "null"}`},
+			{true, `{"type": "boolean"}`},
+			{int32(0), `{"type": "integer", "format": "int32"}`},
+			{0, `{"type": "integer", "format": "int64"}`},
+			{uint(0), `{"type": "integer", "minimum": 0}`},
+			{0.0, `{"type": "number", "format": "double"}`},
+			{"", `{"type": "string"}`},
+			{new(string), `{"type": ["string", "null"]}`},
+			{new(*[]string), `{"type": ["array", "null"], "items": {"type": "string"}}`},
+			{new(time.Time), `{"type": ["string", "null"], "format": "date-time"}`},
+			{[]int{}, `{"type": ["array", "null"], "items": {"type": "integer", "format": "int64"}}`},
+			{NonNullSlice[int]{}, `{"type": "array", "items": {"type": "integer", "format": "int64"}}`},
+			{NonNullSlice[byte]{}, `{"type": "string", "contentEncoding": "base64"}`},
+			{Date{}, `{"description": "A date marshaled as __turborpc.Date(RFC 3339 date).", "type": "string", "pattern": "^(__turborpc\\.Date\\()?\\d{4}-\\d{2}-\\d{2}T"}`},
+		} {
+			assertJSONEqual(t, `{"$schema": "https://json-schema.org/draft/2020-12/schema", `+tt.want[1:], JSONSchema(tt.v))
+		}
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		assertJSONEqual(t, `{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"$ref": "#/$defs/TestSchemaNode",
+			"$defs": {
+				"TestItem": {
+					"type": "object",
+					"properties": {"name": {"type": "string"}},
+					"required": ["name"]
+				},
+				"TestSchemaNode": {
+					"type": "object",
+					"properties": {
+						"id": {"type": "integer", "format": "int64"},
+						"name": {"type": "string"},
+						"note": {"type": "string"},
+						"count": {"type": "string"},
+						"created": {"description": "A date marshaled as __turborpc.Date(RFC 3339 date).", "type": "string", "pattern": "^(__turborpc\\.Date\\()?\\d{4}-\\d{2}-\\d{2}T"},
+						"updated": {"description": "A date marshaled as __turborpc.Date(RFC 3339 date).", "type": ["string", "null"], "pattern": "^(__turborpc\\.Date\\()?\\d{4}-\\d{2}-\\d{2}T"},
+						"item": {"$ref": "#/$defs/TestItem"},
+						"parent": {"anyOf": [{"$ref": "#/$defs/TestSchemaNode"}, {"type": "null"}]},
+						"children": {"type": "array", "items": {"anyOf": [{"$ref": "#/$defs/TestSchemaNode"}, {"type": "null"}]}},
+						"tags": {"type": ["array", "null"], "items": {"type": "string"}},
+						"labels": {"type": "object", "additionalProperties": {"type": "integer", "minimum": 0}},
+						"scores": {"type": ["object", "null"], "additionalProperties": {"type": "number", "format": "double"}},
+						"data": {"type": ["string", "null"], "contentEncoding": "base64"},
+						"point": {"type": "array", "items": {"type": "number", "format": "float"}, "minItems": 2, "maxItems": 2},
+						"ip": {"type": "string"},
+						"at": {"type": "string", "format": "date-time"},
+						"extra": {},
+						"Plain": {"type": "boolean"}
+					},
+					"required": ["id", "name", "count", "created", "item", "parent", "children", "tags", "labels", "scores", "data", "point", "ip", "at", "extra", "Plain"]
+				}
+			}
+		}`, JSONSchema(TestSchemaNode{}))
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		ref := JSONSchema(TestSchemaNode{})
+
+		for i := 0; i < 50; i++ {
+			assertEqual(t, ref, JSONSchema(TestSchemaNode{}))
+		}
+	})
+}
+
+func TestServerMethodJSONSchema(t *testing.T) {
+	rpc := newTestServer()
+	rpc.MustRegister(&TestService1{})
+	rpc.MustRegister(&TestServiceShipment{})
+	rpc.MustRegister(&TestServiceStream{})
+
+	input, output, err := rpc.MethodJSONSchema("TestServiceShipment", "Ship")
+	assertNoError(t, err)
+	assertEqual(t, JSONSchema(TestItem{}), input)
+	assertEqual(t, JSONSchema(TestShipment{}), output)
+
+	input, output, err = rpc.MethodJSONSchema("TestService1", "One")
+	assertNoError(t, err)
+	assertEqual(t, JSONSchema(nil), input)
+	assertEqual(t, JSONSchema(nil), output)
+
+	input, output, err = rpc.MethodJSONSchema("TestServiceStream", "Count")
+	assertNoError(t, err)
+	assertEqual(t, JSONSchema(0), input)
+	assertEqual(t, JSONSchema(0), output)
+
+	_, _, err = rpc.MethodJSONSchema("TestService1", "Missing")
+	assertErrorIs(t, errMethodNotFound, err)
+
+	_, _, err = rpc.MethodJSONSchema("Missing", "One")
+	assertErrorIs(t, errServiceNotFound, err)
+}
@@ -2,6 +2,7 @@ package turborpc
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 )
 
@@ -10,12 +11,43 @@ var (
 	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
 )
 
+// A ServiceOption is an option for a service.
+type ServiceOption func(*service)
+
+// A MethodOption is an option for a service method, see WithMethod.
+type MethodOption func(*method)
+
+// WithMethod applies options to the service method with the given name.
+// Registering the service fails with ErrUnknownMethod if the service has no
+// such method.
+func WithMethod(name string, options ...MethodOption) ServiceOption {
+	return func(s *service) {
+		m, ok := s.methods[name]
+
+		if !ok {
+			if s.err == nil {
+				s.err = fmt.Errorf("%w %q", ErrUnknownMethod, name)
+			}
+
+			return
+		}
+
+		for _, o := range options {
+			o(m)
+		}
+	}
+}
+
 type service struct {
-	name    string
-	version string
-	typ     reflect.Type
-	value   reflect.Value
-	methods map[string]*method
+	name         string
+	version      string
+	typ          reflect.Type
+	value        reflect.Value
+	methods      map[string]*method
+	interceptors []Interceptor
+	limiter      *limiter
+	policies     []Policy
+	err          error
 }
 
 func newService(name string, typ reflect.Type, value reflect.Value, logger func(service, method string)) *service {
@@ -45,7 +77,21 @@ func newService(name string, typ reflect.Type, value reflect.Value, logger func(
 	return s
 }
 
+func isStreamMethod(m reflect.Method) bool {
+	numIn := m.Type.NumIn()
+
+	if !m.IsExported() || numIn < 3 || numIn > 4 || m.Type.In(1) != typeOfContext || !isStreamType(m.Type.In(numIn-1)) {
+		return false
+	}
+
+	return m.Type.NumOut() == 1 && m.Type.Out(0) == typeOfError
+}
+
 func isSuitableMethod(m reflect.Method) bool {
+	if isStreamMethod(m) {
+		return true
+	}
+
 	correctInputsAndOutputs := m.Type.NumIn() > 1 && m.Type.NumIn() < 4 && m.Type.NumOut() > 0 && m.Type.NumOut() < 3
 
 	if !m.IsExported() || !correctInputsAndOutputs || m.Type.In(1) != typeOfContext || (m.Type.NumOut() == 1 && m.Type.Out(0) != typeOfError) || (m.Type.NumOut() == 2 && m.Type.Out(1) != typeOfError) {
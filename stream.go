@@ -0,0 +1,173 @@
+package turborpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+var (
+	errStreamClosed    = errors.New("stream closed")
+	errStreamingMethod = errors.New("cannot call streaming method")
+)
+
+// A Stream is used by a streaming method to send values to the client.
+// Streaming methods look schematically like
+//
+//	func (t T) MethodName(ctx context.Context, argument T1, stream *turborpc.Stream[T2]) error
+//	func (t T) MethodName(ctx context.Context, stream *turborpc.Stream[T2]) error
+//
+// where T1 and T2 can be marshaled by encoding/json. Over HTTP the values are
+// sent as server-sent events. A Stream is closed when the method returns.
+type Stream[T any] struct {
+	send func(v any) error
+}
+
+// Send sends a value to the client. It is safe to call Send from multiple
+// goroutines. Send returns an error if the stream is closed or the client
+// has gone away.
+func (s *Stream[T]) Send(v T) error {
+	return s.send(v)
+}
+
+func (s *Stream[T]) elemType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (s *Stream[T]) setSend(send func(v any) error) {
+	s.send = send
+}
+
+// streamer is implemented by *Stream[T].
+type streamer interface {
+	elemType() reflect.Type
+	setSend(send func(v any) error)
+}
+
+var typeOfStreamer = reflect.TypeOf((*streamer)(nil)).Elem()
+
+func isStreamType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Pointer && typ.Implements(typeOfStreamer)
+}
+
+// newStream returns a new *Stream[T] of type typ that sends values with send.
+func newStream(typ reflect.Type, send func(v any) error) reflect.Value {
+	stream := reflect.New(typ.Elem())
+	stream.Interface().(streamer).setSend(send)
+
+	return stream
+}
+
+func streamElemType(typ reflect.Type) reflect.Type {
+	return reflect.Zero(typ).Interface().(streamer).elemType()
+}
+
+// eventWriter writes server-sent events to a response. The response headers
+// are written with the first event.
+type eventWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	call    *httpCall
+	started bool
+	closed  bool
+}
+
+func (ew *eventWriter) write(event string, v any) error {
+	buf, err := json.Marshal(v)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", errEncodingOutput, err)
+	}
+
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+
+	if ew.closed {
+		return errStreamClosed
+	}
+
+	ew.writeEvent(event, buf)
+
+	return nil
+}
+
+// close writes a last event and closes the writer, after which no more events
+// can be written. Unless force is set the last event is only written if
+// other events have been written before. It returns whether the last event
+// was written.
+func (ew *eventWriter) close(event string, v any, force bool) bool {
+	buf, _ := json.Marshal(v)
+
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+
+	if ew.closed {
+		return false
+	}
+
+	ew.closed = true
+
+	if !ew.started && !force {
+		return false
+	}
+
+	ew.writeEvent(event, buf)
+
+	return true
+}
+
+func (ew *eventWriter) writeEvent(event string, data []byte) {
+	if !ew.started {
+		ew.call.writeHeader(ew.w, true)
+		ew.w.Header().Set("Content-Type", "text/event-stream")
+		ew.w.Header().Set("Cache-Control", "no-cache")
+		ew.w.Header().Set("X-Content-Type-Options", "nosniff")
+		ew.w.WriteHeader(http.StatusOK)
+		ew.started = true
+	}
+
+	if event != "" {
+		fmt.Fprintf(ew.w, "event: %s\n", event)
+	}
+
+	fmt.Fprintf(ew.w, "data: %s\n\n", data)
+
+	if f, ok := ew.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// serveStream answers a call to a streaming method with server-sent events.
+// Every value sent by the method is a "message" event, and the stream ends
+// with either an "end" event or an "error" event with the error response. If
+// the method fails before sending any values a regular error response is
+// sent instead.
+func (rpc *Server) serveStream(w http.ResponseWriter, r *http.Request, s *service, m *method, input io.Reader, codec Codec) {
+	ctx, call := withHTTPCall(r.Context(), r)
+	ew := &eventWriter{w: w, call: call}
+
+	err := m.invokeStream(ctx, CallInfo{Service: s.name, Method: m.name, Stream: true}, s.interceptors, input, codec, func(v any) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return ew.write("", v)
+	})
+
+	if err == nil {
+		ew.close("end", nil, true)
+		return
+	}
+
+	code, err := rpc.errorStatus(err)
+
+	if !ew.close("error", newErrorResponse(code, err), false) {
+		call.writeHeader(w, false)
+		setRetryAfter(w, err)
+		httpError(w, code, err)
+	}
+}
@@ -0,0 +1,70 @@
+package turborpc
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// timeoutHeader is the request header with the number of milliseconds the
+// client waits for a response.
+const timeoutHeader = "X-Timeout-Ms"
+
+// WithTimeout sets the time every method call may take, after which its
+// context is canceled and the call is answered with status code 504 and
+// error code CodeTimeout if the method returns the context's error. The
+// timeout does not apply to streaming methods. It can be overridden per
+// method with WithMethodTimeout. By default there is no timeout.
+//
+// Clients can shorten the timeout of a call with the X-Timeout-Ms header, or
+// the "timeoutMs" field of a WebSocket call.
+func WithTimeout(d time.Duration) ServerOption {
+	return func(r *Server) {
+		r.timeout = d
+	}
+}
+
+// WithMethodTimeout sets the time a call to the method may take, zero means
+// no timeout, see WithTimeout. Unlike WithTimeout it also applies to
+// streaming methods.
+func WithMethodTimeout(d time.Duration) MethodOption {
+	return func(m *method) {
+		m.timeout = d
+	}
+}
+
+// withTimeout returns a context that is canceled when the method's timeout
+// has passed.
+func (m *method) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, m.timeout)
+}
+
+// clientTimeout returns a context that is canceled when a timeout in
+// milliseconds sent by the client has passed. Timeouts that are not positive
+// are ignored, and timeouts too long for a time.Duration are shortened to the
+// longest one.
+func clientTimeout(ctx context.Context, ms int64) (context.Context, context.CancelFunc) {
+	if ms <= 0 {
+		return ctx, func() {}
+	}
+
+	if max := math.MaxInt64 / int64(time.Millisecond); ms > max {
+		ms = max
+	}
+
+	return context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+}
+
+// requestTimeout returns the timeout in milliseconds of a request, or zero if
+// it has none or it cannot be parsed.
+func requestTimeout(r *http.Request) int64 {
+	ms, _ := strconv.ParseInt(r.Header.Get(timeoutHeader), 10, 64)
+
+	return ms
+}
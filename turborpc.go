@@ -13,13 +13,18 @@ Service methods can only look schematically like one off
 	func (t T) MethodName(ctx context.Context) (reply T2, err error)
 	func (t T) MethodName(ctx context.Context) error
 
-where T1 and T2 can be marshaled by encoding/json.
+where T1 and T2 can be marshaled by encoding/json. Service methods can also
+stream values to the client, see Stream. The HTTP request of a call can be
+read with RequestFromContext, and response headers set with SetHeader and
+SetCookie.
 
 The method's second argument represents the argument provided by the
 client; the first return type represents the reply to be returned to
 the client.  The method's error value, if non-nil, is passed back to
-the client HTTP response with status code 500.  If an error is returned,
-the reply will not be sent back to the client.
+the client HTTP response with status code 500, unless the error implements
+HTTPStatusError or is a context error.  If an error is returned, the reply
+will not be sent back to the client.  A method can return an RPCError to
+choose the error code, status code and details of the response.
 */
 package turborpc
 
@@ -31,6 +36,9 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 var (
@@ -38,6 +46,8 @@ var (
 	ErrInvalidService      = errors.New("service must have one or more exported methods")
 	ErrServiceRegistered   = errors.New("service name already registered")
 	ErrMethodErrored       = errors.New("method errored")
+	ErrMethodPanicked      = errors.New("method panicked")
+	ErrUnknownMethod       = errors.New("service has no method")
 )
 
 var (
@@ -47,10 +57,6 @@ var (
 	errNoMethod        = errors.New("no method specified")
 )
 
-var (
-	nullJSON = []byte("null")
-)
-
 // A ServerOption is an option for a Server.
 type ServerOption func(*Server)
 
@@ -88,11 +94,28 @@ func WithNoMethodLogger() ServerOption {
 
 // Server represents an RPC Server.
 type Server struct {
-	errorFilter  func(err error) error
-	methodLogger func(service, method string)
-	services     map[string]*service
-	serveClient  clientGenerator
-	version      string
+	errorFilter         func(err error) error
+	methodLogger        func(service, method string)
+	panicHandler        PanicHandler
+	validator           func(input any) error
+	strictDecoding      bool
+	maxRequestBytes     int64
+	maxBatchSize        int
+	timeout             time.Duration
+	limiter             *limiter
+	rateLimitKey        func(r *http.Request) string
+	authenticator       Authenticator
+	cors                *CORS
+	csrfProtection      bool
+	codecs              map[string]Codec
+	compression         bool
+	compressionMinBytes int
+	interceptors        []Interceptor
+	errorCodes          map[string]reflect.Type
+	services            map[string]*service
+	serveClient         clientGenerator
+	webSocket           *websocket.Upgrader
+	version             string
 }
 
 // NewServer returns a new Server with options applied.
@@ -100,6 +123,11 @@ func NewServer(options ...ServerOption) *Server {
 	rpc := &Server{
 		errorFilter:  nil,
 		methodLogger: makeMethodLogger(fmt.Printf),
+		panicHandler: defaultPanicHandler,
+		rateLimitKey: remoteIP,
+		maxBatchSize: defaultMaxBatchSize,
+		codecs:       defaultCodecs(),
+		errorCodes:   make(map[string]reflect.Type),
 		services:     make(map[string]*service),
 	}
 
@@ -107,6 +135,10 @@ func NewServer(options ...ServerOption) *Server {
 		o(rpc)
 	}
 
+	if rpc.cors != nil && rpc.webSocket != nil {
+		rpc.webSocket.CheckOrigin = rpc.cors.checkOrigin
+	}
+
 	return rpc
 }
 
@@ -130,23 +162,24 @@ func validServiceType(typ reflect.Type) bool {
 //	func (t T) MethodName(ctx context.Context) (reply T2, err error)
 //	func (t T) MethodName(ctx context.Context) error
 //
-// where T1 and T2 can be marshaled by encoding/json.
-func (rpc *Server) Register(rcvr any) error {
-	return rpc.RegisterName(findServiceName(reflect.TypeOf(rcvr)), rcvr)
+// where T1 and T2 can be marshaled by encoding/json, as well as streaming
+// methods, see Stream. The options are applied to the registered service.
+func (rpc *Server) Register(rcvr any, options ...ServiceOption) error {
+	return rpc.RegisterName(findServiceName(reflect.TypeOf(rcvr)), rcvr, options...)
 }
 
 // MustRegister registers a receiver with the server using a service name
 // derived from the receiver's type. If the registration fails, it panics with
 // the encountered error.
-func (rpc *Server) MustRegister(rcvr any) {
-	if err := rpc.RegisterName(findServiceName(reflect.TypeOf(rcvr)), rcvr); err != nil {
+func (rpc *Server) MustRegister(rcvr any, options ...ServiceOption) {
+	if err := rpc.RegisterName(findServiceName(reflect.TypeOf(rcvr)), rcvr, options...); err != nil {
 		panic(err)
 	}
 }
 
 // RegisterName is like Register but uses the provided name for the service
 // instead of inferring it from the receiver's type.
-func (rpc *Server) RegisterName(name string, r any) error {
+func (rpc *Server) RegisterName(name string, r any, options ...ServiceOption) error {
 	if name == defaultRPCClassName {
 		return fmt.Errorf("%s: %w", name, ErrReservedServiceName)
 	}
@@ -161,32 +194,136 @@ func (rpc *Server) RegisterName(name string, r any) error {
 		return ErrInvalidService
 	}
 
-	rpc.services[name] = newService(name, typ, reflect.ValueOf(r), rpc.methodLogger)
+	s := newService(name, typ, reflect.ValueOf(r), rpc.methodLogger)
+
+	for _, m := range s.methods {
+		m.strictDecoding = rpc.strictDecoding
+		m.maxRequestBytes = rpc.maxRequestBytes
+
+		if m.stream == nil {
+			m.timeout = rpc.timeout
+		}
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	if s.err != nil {
+		return fmt.Errorf("%s: %w", name, s.err)
+	}
+
+	s.interceptors = append(append([]Interceptor(nil), rpc.interceptors...), s.interceptors...)
+	s.interceptors = append(s.interceptors, validateInterceptor(rpc.validator))
+
+	for _, m := range s.methods {
+		for _, l := range []*limiter{rpc.limiter, s.limiter, m.limiter} {
+			if l != nil {
+				m.limiters = append(m.limiters, l)
+			}
+		}
+
+		if m.rateLimiter != nil {
+			m.rateLimiter.key = rpc.rateLimitKey
+		}
+
+		m.policies = append(append([]Policy(nil), s.policies...), m.policies...)
+	}
+
+	rpc.services[name] = s
 
 	rpc.version = calculateServerVersion(rpc.metadata())
 
 	return nil
 }
 
-func (rpc *Server) call(ctx context.Context, service string, method string, input []byte) ([]byte, error) {
+func (rpc *Server) lookup(service string, method string) (*service, *method, error) {
+	if service == "" {
+		return nil, nil, errNoService
+	}
+
+	if method == "" {
+		return nil, nil, errNoMethod
+	}
+
 	s, ok := rpc.services[service]
 
 	if !ok {
-		return nil, fmt.Errorf("%w %q", errServiceNotFound, service)
+		return nil, nil, fmt.Errorf("%w %q", errServiceNotFound, service)
 	}
 
 	m, ok := s.methods[method]
 
 	if !ok {
-		return nil, fmt.Errorf("%w %q", errMethodNotFound, method)
+		return nil, nil, fmt.Errorf("%w %q", errMethodNotFound, method)
+	}
+
+	return s, m, nil
+}
+
+// call calls a method with an input decoded with codec and returns its
+// output.
+func (rpc *Server) call(ctx context.Context, s *service, m *method, input io.Reader, codec Codec) (any, error) {
+	if m.stream != nil {
+		return nil, fmt.Errorf("%w %q", errStreamingMethod, m.name)
+	}
+
+	return m.invoke(ctx, CallInfo{Service: s.name, Method: m.name}, s.interceptors, input, codec)
+}
+
+// errorStatus returns the HTTP status code for an error returned by call and
+// the error that should be reported to the client. Recovered panics are
+// passed to the panic handler.
+func (rpc *Server) errorStatus(err error) (int, error) {
+	var panicErr *panicError
+	var retryErr *retryError
+
+	if errors.As(err, &retryErr) {
+		return retryErr.status, err
+	}
+
+	if errors.As(err, &panicErr) {
+		if rpc.panicHandler != nil {
+			rpc.panicHandler(panicErr.info.Service, panicErr.info.Method, panicErr.value, panicErr.stack)
+		}
+
+		return http.StatusInternalServerError, ErrMethodPanicked
 	}
 
-	return m.invoke(ctx, input)
+	switch {
+	case errors.Is(err, errNoService) || errors.Is(err, errNoMethod) || errors.Is(err, errStreamingMethod):
+		return http.StatusBadRequest, err
+	case errors.Is(err, errServiceNotFound) || errors.Is(err, errMethodNotFound):
+		return http.StatusNotFound, err
+	case errors.Is(err, ErrUnauthenticated):
+		return http.StatusUnauthorized, err
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden, err
+	case errors.Is(err, errEncodingOutput):
+		return http.StatusInternalServerError, err
+	}
+
+	code := methodErrorStatus(err)
+
+	if rpc.errorFilter == nil {
+		return code, err
+	}
+
+	err = rpc.errorFilter(err)
+
+	var statusErr HTTPStatusError
+	if errors.As(err, &statusErr) {
+		code = statusErr.HTTPStatus()
+	}
+
+	return code, err
 }
 
 type errorResponse struct {
 	Status  int    `json:"status"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
 }
 
 // Error replies to the request with the specified error message and HTTP code.
@@ -194,101 +331,155 @@ type errorResponse struct {
 // otherwise end the request; the caller should ensure no further writes are
 // done to w.
 func Error(w http.ResponseWriter, error string, code int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(code)
-
-	buf, _ := json.Marshal(errorResponse{
+	writeErrorResponse(w, JSONCodec, errorResponse{
 		Status:  code,
+		Code:    errorCode(code),
 		Message: error,
 	})
-
-	w.Write(buf)
 }
 
-func httpError(w http.ResponseWriter, code int, err error) {
-	if err == nil {
-		Error(w, ErrMethodErrored.Error(), code)
-		return
+// writeErrorResponse writes an error response encoded with codec, or with
+// JSON if codec cannot encode it.
+func writeErrorResponse(w http.ResponseWriter, codec Codec, resp errorResponse) {
+	buf, err := codec.Marshal(resp)
+
+	if err != nil {
+		codec = JSONCodec
+		buf, _ = json.Marshal(resp)
 	}
 
-	Error(w, err.Error(), code)
+	writeResponse(w, codec, resp.Status, buf)
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	writeErrorResponse(w, JSONCodec, newErrorResponse(code, err))
 }
 
 type outputResponse struct {
-	Output json.RawMessage `json:"output"`
+	Output any `json:"output"`
 }
 
-func httpOK(w http.ResponseWriter, output json.RawMessage) {
-	w.Header().Set("Content-Type", "application/json")
+// writeResponse writes a response body that is encoded with codec.
+func writeResponse(w http.ResponseWriter, codec Codec, code int, buf []byte) {
+	w.Header().Set("Content-Type", codec.ContentType())
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(http.StatusOK)
-
-	buf, _ := json.Marshal(outputResponse{
-		Output: output,
-	})
+	w.WriteHeader(code)
 
 	w.Write(buf)
 }
 
-// ServeHTTP implements an http.Handler that answers RPC requests.
+// ServeHTTP implements an http.Handler that answers RPC requests. The input
+// and output of a call are encoded with the codecs picked from the request's
+// Content-Type and Accept headers, see Codec. Request bodies may be
+// compressed with gzip or deflate, and responses are compressed if the server
+// has WithCompression. A request with the query parameter "batch" is answered
+// as a batch of calls, see serveBatch, calls to streaming methods are answered
+// with server-sent events, see serveStream, and GET requests calling queries
+// are answered by serveQuery. If the server has WebSocket support, requests to
+// upgrade the connection are answered by serveWebSocket. If the server has
+// WithCORS, preflight requests are answered before anything else.
 func (rpc *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if rpc.serveClient != nil && r.Method == http.MethodGet {
+	if rpc.webSocket != nil && websocket.IsWebSocketUpgrade(r) {
+		if r, ok := rpc.authenticate(w, r); ok {
+			rpc.serveWebSocket(w, r)
+		}
+
+		return
+	}
+
+	if rpc.cors != nil && rpc.cors.serveCORS(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	if rpc.serveClient != nil && r.Method == http.MethodGet && !query.Has("method") {
 		sourceClient := rpc.serveClient.GenerateClient(rpc.metadata())
 		w.Header().Set("Content-Type", sourceClient.ContentType)
 		w.Write([]byte(sourceClient.SourceCode))
 		return
 	}
 
-	if r.Method != http.MethodPost {
+	isQuery := r.Method == http.MethodGet && query.Has("method")
+
+	if r.Method != http.MethodPost && !isQuery {
 		http.NotFound(w, r)
 		return
 	}
 
 	w.Header().Set("X-Server-Version", rpc.version)
 
-	service := r.URL.Query().Get("service")
-	if service == "" {
-		httpError(w, http.StatusBadRequest, errNoService)
+	if rpc.csrfProtection && !isQuery && isSimpleRequest(r) {
+		httpError(w, http.StatusForbidden, errCrossSiteRequest)
 		return
 	}
 
-	method := r.URL.Query().Get("method")
-	if method == "" {
-		httpError(w, http.StatusBadRequest, errNoMethod)
+	ctx, cancel := clientTimeout(r.Context(), requestTimeout(r))
+	defer cancel()
+
+	r = r.WithContext(ctx)
+
+	r, ok := rpc.authenticate(w, r)
+
+	if !ok {
 		return
 	}
 
-	input, err := io.ReadAll(r.Body)
-
-	if err != nil {
-		httpError(w, http.StatusInternalServerError, err)
+	if isQuery {
+		rpc.serveQuery(w, r, query)
 		return
 	}
 
-	buf, err := rpc.call(r.Context(), service, method, input)
+	if err := decompressRequest(r); err != nil {
+		code := http.StatusBadRequest
 
-	if err != nil {
-		switch {
-		case errors.Is(err, errServiceNotFound) || errors.Is(err, errMethodNotFound):
-			httpError(w, http.StatusNotFound, err)
-		case errors.Is(err, errEncodingOutput):
-			httpError(w, http.StatusInternalServerError, err)
-		default:
-			if rpc.errorFilter != nil {
-				httpError(w, http.StatusBadRequest, rpc.errorFilter(err))
-			} else {
-				httpError(w, http.StatusBadRequest, err)
-			}
+		if errors.Is(err, errUnsupportedEncoding) {
+			code = http.StatusUnsupportedMediaType
 		}
 
+		httpError(w, code, err)
+		return
+	}
+
+	if query.Has("batch") {
+		rpc.serveBatch(w, r)
+		return
+	}
+
+	in := rpc.requestCodec(r)
+	out := rpc.responseCodec(r, in)
+
+	s, m, err := rpc.lookup(query.Get("service"), query.Get("method"))
+
+	var input io.Reader
+	if err == nil {
+		input = m.limitInput(w, r.Body)
+	}
+
+	if err == nil && m.stream != nil {
+		rpc.serveStream(w, r, s, m, input, in)
 		return
 	}
 
-	if buf == nil {
-		httpOK(w, nullJSON)
+	ctx, call := withHTTPCall(r.Context(), r)
+
+	var output any
+	if err == nil {
+		output, err = rpc.call(ctx, s, m, input, in)
+	}
+
+	var buf []byte
+	if err == nil {
+		buf, err = encodeOutput(out, CallInfo{Service: s.name, Method: m.name}, outputResponse{Output: output})
+	}
+
+	if err != nil {
+		call.writeHeader(w, false)
+		setRetryAfter(w, err)
+		writeErrorResponse(w, out, newErrorResponse(rpc.errorStatus(err)))
 		return
 	}
 
-	httpOK(w, buf)
+	call.writeHeader(w, true)
+	writeResponse(w, out, http.StatusOK, rpc.compress(w, r, buf))
 }
@@ -2,6 +2,8 @@ package turborpc
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
 	"errors"
@@ -10,7 +12,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 type TestService1 struct{}
@@ -371,7 +377,7 @@ func TestServerErrors(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		assertEqual(t, http.StatusBadRequest, res.StatusCode)
+		assertEqual(t, http.StatusInternalServerError, res.StatusCode)
 
 		o := MustUnmarshalJSON[errorResponse](res.Body)
 
@@ -463,7 +469,7 @@ func TestServerErrors(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		assertEqual(t, http.StatusBadRequest, res.StatusCode)
+		assertEqual(t, http.StatusInternalServerError, res.StatusCode)
 
 		o := MustUnmarshalJSON[errorResponse](res.Body)
 
@@ -486,7 +492,7 @@ func TestServerErrors(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		assertEqual(t, http.StatusBadRequest, res.StatusCode)
+		assertEqual(t, http.StatusInternalServerError, res.StatusCode)
 
 		o := MustUnmarshalJSON[errorResponse](res.Body)
 
@@ -494,14 +500,251 @@ func TestServerErrors(t *testing.T) {
 	})
 }
 
-func TestServerOptions(t *testing.T) {
-	t.Run("serve javascript client", func(t *testing.T) {
-		rpc := newTestServer(WithServerJavaScriptClient())
+type TestServiceCoded struct{}
+
+type TestStockDetails struct {
+	Available int `json:"available"`
+}
+
+func (c *TestServiceCoded) OutOfStock(ctx context.Context) error {
+	return &RPCError{Code: "out_of_stock", Status: http.StatusConflict, Message: "out of stock", Details: TestStockDetails{Available: 2}}
+}
+
+func (c *TestServiceCoded) Wrapped(ctx context.Context) error {
+	return fmt.Errorf("ordering: %w", &RPCError{Code: "out_of_stock", Message: "out of stock"})
+}
+
+func (c *TestServiceCoded) Uncoded(ctx context.Context) error {
+	return &RPCError{Message: "uncoded"}
+}
+
+func TestServerRPCError(t *testing.T) {
+	serve := func(rpc *Server, service, method string) (int, errorResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/?service="+service+"&method="+method, nil)
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		var o struct {
+			errorResponse
+			Details json.RawMessage `json:"details"`
+		}
+
+		assertNoError(t, json.NewDecoder(res.Body).Decode(&o))
+
+		o.errorResponse.Details = string(o.Details)
+
+		return res.StatusCode, o.errorResponse
+	}
+
+	t.Run("code status and details", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceCoded{})
+
+		status, o := serve(rpc, "TestServiceCoded", "OutOfStock")
+
+		assertEqual(t, http.StatusConflict, status)
+		assertEqual(t, http.StatusConflict, o.Status)
+		assertEqual(t, "out_of_stock", o.Code)
+		assertEqual(t, "out of stock", o.Message)
+		assertEqual(t, `{"available":2}`, o.Details)
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceCoded{})
+
+		status, o := serve(rpc, "TestServiceCoded", "Wrapped")
+
+		assertEqual(t, http.StatusBadRequest, status)
+		assertEqual(t, "out_of_stock", o.Code)
+		assertEqual(t, "ordering: out of stock", o.Message)
+		assertEqual(t, "", o.Details)
+	})
+
+	t.Run("default code", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceCoded{})
+
+		status, o := serve(rpc, "TestServiceCoded", "Uncoded")
+
+		assertEqual(t, http.StatusBadRequest, status)
+		assertEqual(t, CodeBadRequest, o.Code)
+	})
+
+	t.Run("builtin code", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceCoded{})
+
+		status, o := serve(rpc, "TestServiceCoded", "NotFound")
+
+		assertEqual(t, http.StatusNotFound, status)
+		assertEqual(t, CodeNotFound, o.Code)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		rpc := newTestServer(WithErrorFilter(func(err error) error {
+			return &RPCError{Code: "filtered", Status: http.StatusTeapot, Message: err.Error()}
+		}))
+		rpc.Register(&TestServiceCoded{})
+
+		status, o := serve(rpc, "TestServiceCoded", "Uncoded")
+
+		assertEqual(t, http.StatusTeapot, status)
+		assertEqual(t, "filtered", o.Code)
+		assertEqual(t, "uncoded", o.Message)
+	})
+
+	t.Run("declared codes in client", func(t *testing.T) {
+		rpc := newTestServer(WithErrorCode("out_of_stock", TestStockDetails{}), WithErrorCode("sold_out", nil))
+		rpc.Register(&TestServiceCoded{})
+
+		client := rpc.TypeScriptClient()
+
+		for _, code := range []string{CodeBadRequest, CodeInternal, CodeNotFound, "out_of_stock", "sold_out"} {
+			if !strings.Contains(client, fmt.Sprintf("| %q", code)) {
+				t.Errorf("expected client to contain error code %q", code)
+			}
+		}
+	})
+}
+
+type teapotError struct{}
 
+func (teapotError) Error() string   { return "teapot" }
+func (teapotError) HTTPStatus() int { return http.StatusTeapot }
+
+type TestServiceStatus struct{}
+
+func (c *TestServiceStatus) Teapot(ctx context.Context) error {
+	return fmt.Errorf("brewing: %w", teapotError{})
+}
+
+func (c *TestServiceStatus) Deadline(ctx context.Context) error {
+	return context.DeadlineExceeded
+}
+
+func (c *TestServiceStatus) Canceled(ctx context.Context) error {
+	return fmt.Errorf("querying: %w", context.Canceled)
+}
+
+func (c *TestServiceStatus) Plain(ctx context.Context) error {
+	return errTest
+}
+
+func (c *TestServiceStatus) Input(ctx context.Context, n int) error {
+	return nil
+}
+
+func TestServerErrorStatus(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		method  string
+		input   string
+		options []ServerOption
+		status  int
+		code    string
+	}{
+		{desc: "http status error", method: "Teapot", status: http.StatusTeapot, code: CodeBadRequest},
+		{desc: "deadline exceeded", method: "Deadline", status: http.StatusGatewayTimeout, code: CodeTimeout},
+		{desc: "canceled", method: "Canceled", status: 499, code: CodeCanceled},
+		{desc: "internal", method: "Plain", status: http.StatusInternalServerError, code: CodeInternal},
+		{desc: "bad input", method: "Input", input: `"a"`, status: http.StatusBadRequest, code: CodeMalformedInput},
+		{
+			desc:    "filtered keeps status",
+			method:  "Deadline",
+			options: []ServerOption{WithErrorFilter(func(err error) error { return errors.New("hidden") })},
+			status:  http.StatusGatewayTimeout,
+			code:    CodeTimeout,
+		},
+		{
+			desc:    "filtered chooses status",
+			method:  "Plain",
+			options: []ServerOption{WithErrorFilter(func(err error) error { return teapotError{} })},
+			status:  http.StatusTeapot,
+			code:    CodeBadRequest,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rpc := newTestServer(tC.options...)
+			rpc.Register(&TestServiceStatus{})
+
+			req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceStatus&method="+tC.method, strings.NewReader(tC.input))
+			w := httptest.NewRecorder()
+
+			rpc.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			o := MustUnmarshalJSON[errorResponse](res.Body)
+
+			assertEqual(t, tC.status, res.StatusCode)
+			assertEqual(t, tC.status, o.Status)
+			assertEqual(t, tC.code, o.Code)
+		})
+	}
+}
+
+type TestServicePanic struct{}
+
+func (c *TestServicePanic) Panic(ctx context.Context) error {
+	panic("boom")
+}
+
+func (c *TestServicePanic) PanicStream(ctx context.Context, stream *Stream[int]) error {
+	stream.Send(1)
+	panic("boom")
+}
+
+func TestServerPanic(t *testing.T) {
+	type panicked struct {
+		service, method string
+		v               any
+		stack           []byte
+	}
+
+	t.Run("call", func(t *testing.T) {
+		var p panicked
+		rpc := newTestServer(WithPanicHandler(func(service, method string, v any, stack []byte) {
+			p = panicked{service, method, v, stack}
+		}))
+
+		rpc.Register(&TestServicePanic{})
+
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServicePanic&method=Panic", nil)
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		o := MustUnmarshalJSON[errorResponse](res.Body)
+
+		assertEqual(t, http.StatusInternalServerError, res.StatusCode)
+		assertEqual(t, ErrMethodPanicked.Error(), o.Message)
+		assertEqual(t, "TestServicePanic", p.service)
+		assertEqual(t, "Panic", p.method)
+		assertEqual(t, any("boom"), p.v)
+		assertEqual(t, true, strings.Contains(string(p.stack), "TestServicePanic"))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		rpc := newTestServer(WithPanicHandler(nil))
+
+		rpc.Register(&TestServicePanic{})
 		rpc.Register(&TestService1{})
-		rpc.Register(&TestService2{})
 
-		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req := httptest.NewRequest(http.MethodPost, "/?batch", strings.NewReader(`[
+			{"service": "TestServicePanic", "method": "Panic"},
+			{"service": "TestService1", "method": "Three", "input": 0}
+		]`))
 		w := httptest.NewRecorder()
 
 		rpc.ServeHTTP(w, req)
@@ -509,20 +752,177 @@ func TestServerOptions(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		jsClient, err := io.ReadAll(res.Body)
+		results := MustUnmarshalJSON[[]struct {
+			Status int             `json:"status"`
+			Output json.RawMessage `json:"output"`
+		}](res.Body)
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, http.StatusInternalServerError, results[0].Status)
+		assertEqual(t, "3", string(results[1].Output))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		rpc := newTestServer(WithPanicHandler(nil))
+
+		rpc.Register(&TestServicePanic{})
+
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServicePanic&method=PanicStream", nil)
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
 
 		assertNoError(t, err)
+		assertEqual(t, "data: 1\n\nevent: error\ndata: {\"status\":500,\"code\":\"internal\",\"message\":\"method panicked\"}\n\n", string(body))
+	})
+}
 
-		assertEqual(t, rpc.JavaScriptClient(), string(jsClient))
+type TestSignup struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func (s *TestSignup) Validate() error {
+	var err ValidationError
+
+	if s.Name == "" {
+		err.Fields = append(err.Fields, FieldError{Field: "name", Message: "is required"})
+	}
+
+	if s.Age < 18 {
+		err.Fields = append(err.Fields, FieldError{Field: "age", Message: "must be at least 18"})
+	}
+
+	if len(err.Fields) > 0 {
+		return err
+	}
+
+	return nil
+}
+
+type TestAge int
+
+func (a TestAge) Validate() error {
+	if a < 0 {
+		return FieldError{Field: "age", Message: "must not be negative"}
+	}
+
+	return nil
+}
+
+type TestServiceValidate struct{}
+
+func (c *TestServiceValidate) Signup(ctx context.Context, s TestSignup) (string, error) {
+	return s.Name, nil
+}
+
+func (c *TestServiceValidate) SetAge(ctx context.Context, a TestAge) error {
+	return nil
+}
+
+func TestServerValidate(t *testing.T) {
+	type response struct {
+		Status  int             `json:"status"`
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Details ValidationError `json:"details"`
+	}
+
+	serve := func(rpc *Server, method, input string) (int, response) {
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceValidate&method="+method, strings.NewReader(input))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		return res.StatusCode, MustUnmarshalJSON[response](res.Body)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceValidate{})
+
+		status, _ := serve(rpc, "Signup", `{"name": "a", "age": 18}`)
+
+		assertEqual(t, http.StatusOK, status)
 	})
 
-	t.Run("not serve javascript client", func(t *testing.T) {
+	t.Run("field errors", func(t *testing.T) {
 		rpc := newTestServer()
+		rpc.Register(&TestServiceValidate{})
 
-		rpc.Register(&TestService1{})
-		rpc.Register(&TestService2{})
+		status, o := serve(rpc, "Signup", `{"age": 3}`)
 
-		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		assertEqual(t, http.StatusUnprocessableEntity, status)
+		assertEqual(t, CodeInvalidInput, o.Code)
+		assertEqual(t, "name: is required; age: must be at least 18", o.Message)
+		assertEqual(t, 2, len(o.Details.Fields))
+		assertEqual(t, FieldError{Field: "name", Message: "is required"}, o.Details.Fields[0])
+	})
+
+	t.Run("single field error", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceValidate{})
+
+		status, o := serve(rpc, "SetAge", `-1`)
+
+		assertEqual(t, http.StatusUnprocessableEntity, status)
+		assertEqual(t, 1, len(o.Details.Fields))
+		assertEqual(t, "age", o.Details.Fields[0].Field)
+	})
+
+	t.Run("validator", func(t *testing.T) {
+		var validated any
+		rpc := newTestServer(WithValidator(func(input any) error {
+			validated = input
+			return errors.New("not allowed")
+		}))
+		rpc.Register(&TestServiceValidate{})
+
+		status, o := serve(rpc, "Signup", `{"name": "a", "age": 18}`)
+
+		assertEqual(t, http.StatusUnprocessableEntity, status)
+		assertEqual(t, "not allowed", o.Message)
+		assertEqual(t, 0, len(o.Details.Fields))
+		assertEqual(t, any(TestSignup{Name: "a", Age: 18}), validated)
+	})
+}
+
+type TestItem struct {
+	Name string `json:"name"`
+}
+
+type TestOrder struct {
+	Items []TestItem `json:"items"`
+	Note  string
+}
+
+type TestServiceOrder struct{}
+
+func (c *TestServiceOrder) Place(ctx context.Context, o TestOrder) (int, error) {
+	return len(o.Items), nil
+}
+
+func (c *TestServiceOrder) Loose(ctx context.Context, o TestOrder) (int, error) {
+	return len(o.Items), nil
+}
+
+func TestServerStrictDecoding(t *testing.T) {
+	type response struct {
+		Code    string     `json:"code"`
+		Message string     `json:"message"`
+		Details FieldError `json:"details"`
+	}
+
+	serve := func(rpc *Server, method, input string) (int, response) {
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceOrder&method="+method, strings.NewReader(input))
 		w := httptest.NewRecorder()
 
 		rpc.ServeHTTP(w, req)
@@ -530,20 +930,2038 @@ func TestServerOptions(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		assertEqual(t, http.StatusNotFound, res.StatusCode)
+		return res.StatusCode, MustUnmarshalJSON[response](res.Body)
+	}
+
+	t.Run("lenient by default", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceOrder{})
+
+		status, _ := serve(rpc, "Place", `{"items": [{"nmae": "a"}]}`)
+
+		assertEqual(t, http.StatusOK, status)
 	})
 
-	t.Run("logger", func(t *testing.T) {
+	t.Run("unknown field", func(t *testing.T) {
+		rpc := newTestServer(WithStrictDecoding())
+		rpc.Register(&TestServiceOrder{})
+
+		status, o := serve(rpc, "Place", `{"note": "", "items": [{"name": "a"}, {"nmae": "b"}]}`)
+
+		assertEqual(t, http.StatusBadRequest, status)
+		assertEqual(t, CodeMalformedInput, o.Code)
+		assertEqual(t, "items.1.nmae", o.Details.Field)
+	})
+
+	t.Run("unknown field in input order", func(t *testing.T) {
+		rpc := newTestServer(WithStrictDecoding())
+		rpc.Register(&TestServiceOrder{})
+
+		status, o := serve(rpc, "Place", `{"items": [{"name": "a", "zz": 1}], "a": {"yy": 1}}`)
+
+		assertEqual(t, http.StatusBadRequest, status)
+		assertEqual(t, "items.0.zz", o.Details.Field)
+	})
+
+	t.Run("unknown field after large input", func(t *testing.T) {
+		rpc := newTestServer(WithStrictDecoding())
+		rpc.Register(&TestServiceOrder{})
+
+		status, o := serve(rpc, "Place", `{"note": "`+strings.Repeat("a", 1<<20)+`", "items": [{"nmae": "b"}]}`)
+
+		assertEqual(t, http.StatusBadRequest, status)
+		assertEqual(t, "items.0.nmae", o.Details.Field)
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		rpc := newTestServer(WithStrictDecoding())
+		rpc.Register(&TestServiceOrder{})
+
+		status, o := serve(rpc, "Place", `{"items": []} {}`)
+
+		assertEqual(t, http.StatusBadRequest, status)
+		assertEqual(t, "decoding input: "+errTrailingData.Error(), o.Message)
+	})
+
+	t.Run("type error", func(t *testing.T) {
+		rpc := newTestServer(WithStrictDecoding())
+		rpc.Register(&TestServiceOrder{})
+
+		status, o := serve(rpc, "Place", `{"items": [{"name": 1}]}`)
+
+		assertEqual(t, http.StatusBadRequest, status)
+		assertEqual(t, true, strings.HasSuffix(o.Details.Field, "name"))
+	})
+
+	t.Run("method override", func(t *testing.T) {
+		rpc := newTestServer(WithStrictDecoding())
+		rpc.Register(&TestServiceOrder{}, WithMethod("Loose", WithMethodStrictDecoding(false)))
+
+		status, _ := serve(rpc, "Loose", `{"items": [{"nmae": "a"}]}`)
+
+		assertEqual(t, http.StatusOK, status)
+
+		status, _ = serve(rpc, "Place", `{"items": [{"nmae": "a"}]}`)
+
+		assertEqual(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
 		rpc := newTestServer()
+		err := rpc.Register(&TestServiceOrder{}, WithMethod("Missing", WithMethodStrictDecoding(true)))
 
-		var called bool
-		rpc.methodLogger = makeMethodLogger(func(format string, a ...any) (n int, err error) {
-			called = true
-			return 0, nil
-		})
+		assertEqual(t, true, errors.Is(err, ErrUnknownMethod))
+	})
+}
 
-		rpc.Register(&TestService1{})
+func TestServerMaxRequestBytes(t *testing.T) {
+	serve := func(rpc *Server, target string, body io.Reader) (int, errorResponse) {
+		req := httptest.NewRequest(http.MethodPost, target, body)
+		w := httptest.NewRecorder()
 
-		assertEqual(t, called, true)
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		return res.StatusCode, MustUnmarshalJSON[errorResponse](res.Body)
+	}
+
+	long := fmt.Sprintf("%q", strings.Repeat("a", 64))
+
+	t.Run("within limit", func(t *testing.T) {
+		rpc := newTestServer(WithMaxRequestBytes(16))
+		rpc.Register(&TestServiceEcho{})
+
+		status, _ := serve(rpc, "/?service=TestServiceEcho&method=Echo", strings.NewReader(`"short"`))
+
+		assertEqual(t, http.StatusOK, status)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		rpc := newTestServer(WithMaxRequestBytes(16))
+		rpc.Register(&TestServiceEcho{})
+
+		status, o := serve(rpc, "/?service=TestServiceEcho&method=Echo", strings.NewReader(long))
+
+		assertEqual(t, http.StatusRequestEntityTooLarge, status)
+		assertEqual(t, CodePayloadTooLarge, o.Code)
+	})
+
+	t.Run("method override", func(t *testing.T) {
+		rpc := newTestServer(WithMaxRequestBytes(16))
+		rpc.Register(&TestServiceEcho{}, WithMethod("Echo", WithMethodMaxRequestBytes(128)))
+
+		status, _ := serve(rpc, "/?service=TestServiceEcho&method=Echo", strings.NewReader(long))
+
+		assertEqual(t, http.StatusOK, status)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		rpc := newTestServer(WithMaxRequestBytes(128))
+		rpc.Register(&TestServiceEcho{})
+
+		status, _ := serve(rpc, "/?batch", strings.NewReader(`[{"service": "TestServiceEcho", "method": "Echo", "input": `+long+`}, {"service": "TestServiceEcho", "method": "Echo", "input": `+long+`}]`))
+
+		assertEqual(t, http.StatusRequestEntityTooLarge, status)
+	})
+
+	t.Run("batch call", func(t *testing.T) {
+		rpc := newTestServer(WithMaxRequestBytes(128))
+		rpc.Register(&TestServiceEcho{}, WithMethod("Echo", WithMethodMaxRequestBytes(16)))
+
+		req := httptest.NewRequest(http.MethodPost, "/?batch", strings.NewReader(`[{"service": "TestServiceEcho", "method": "Echo", "input": `+long+`}]`))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		results := MustUnmarshalJSON[[]errorResponse](res.Body)
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, http.StatusRequestEntityTooLarge, results[0].Status)
+	})
+
+	t.Run("websocket message limit", func(t *testing.T) {
+		for _, tt := range []struct {
+			name    string
+			server  []ServerOption
+			service []ServiceOption
+			want    int64
+		}{
+			{"no limit", nil, nil, wsMaxInputBytes},
+			{"server", []ServerOption{WithMaxRequestBytes(16)}, nil, 16},
+			{"method", []ServerOption{WithMaxRequestBytes(16)}, []ServiceOption{WithMethod("Echo", WithMethodMaxRequestBytes(128))}, 128},
+			{"method no limit", []ServerOption{WithMaxRequestBytes(16)}, []ServiceOption{WithMethod("Echo", WithMethodMaxRequestBytes(0))}, wsMaxInputBytes},
+			{"larger than default", nil, []ServiceOption{WithMethod("Echo", WithMethodMaxRequestBytes(wsMaxInputBytes*2))}, wsMaxInputBytes * 2},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				rpc := newTestServer(tt.server...)
+				rpc.MustRegister(&TestServiceEcho{}, tt.service...)
+
+				assertEqual(t, tt.want, rpc.maxInputBytes(wsMaxInputBytes))
+			})
+		}
+	})
+
+	t.Run("cannot read input", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceEcho{})
+
+		status, o := serve(rpc, "/?service=TestServiceEcho&method=Echo", errReader{})
+
+		assertEqual(t, http.StatusInternalServerError, status)
+		assertEqual(t, CodeInternal, o.Code)
+	})
+}
+
+type TestShipment struct {
+	Name    string               `json:"name"`
+	Shipped Date                 `json:"shipped"`
+	Tags    NonNullSlice[string] `json:"tags"`
+}
+
+type TestServiceShipment struct{}
+
+func (c *TestServiceShipment) Ship(ctx context.Context, item TestItem) (TestShipment, error) {
+	return TestShipment{Name: item.Name, Shipped: Date(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))}, nil
+}
+
+type TestServiceSchedule struct{}
+
+func (c *TestServiceSchedule) Delay(ctx context.Context, s TestShipment) (TestShipment, error) {
+	s.Shipped = Date(time.Time(s.Shipped).Add(time.Hour))
+	return s, nil
+}
+
+func TestServerCodecs(t *testing.T) {
+	serve := func(rpc *Server, method string, body []byte, contentType, accept string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceShipment&method="+method, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	type shipment struct {
+		Name    string    `json:"name"`
+		Shipped time.Time `json:"shipped"`
+		Tags    []string  `json:"tags"`
+	}
+
+	shipped := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, codec := range []Codec{MessagePackCodec, CBORCodec} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			rpc := newTestServer()
+			rpc.Register(&TestServiceShipment{})
+
+			body, err := codec.Marshal(TestItem{Name: "a"})
+			assertNoError(t, err)
+
+			res := serve(rpc, "Ship", body, codec.ContentType(), "")
+			defer res.Body.Close()
+
+			assertEqual(t, http.StatusOK, res.StatusCode)
+			assertEqual(t, codec.ContentType(), res.Header.Get("Content-Type"))
+
+			var o struct {
+				Output shipment `json:"output"`
+			}
+
+			assertNoError(t, codec.Decode(res.Body, &o, false))
+			assertEqual(t, "a", o.Output.Name)
+			assertEqual(t, true, shipped.Equal(o.Output.Shipped))
+			assertEqual(t, 0, len(o.Output.Tags))
+		})
+	}
+
+	for _, codec := range []Codec{JSONCodec, MessagePackCodec, CBORCodec} {
+		t.Run("date input "+codec.ContentType(), func(t *testing.T) {
+			rpc := newTestServer()
+			rpc.Register(&TestServiceSchedule{})
+
+			body, err := codec.Marshal(TestShipment{Name: "a", Shipped: Date(shipped)})
+			assertNoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceSchedule&method=Delay", bytes.NewReader(body))
+			req.Header.Set("Content-Type", codec.ContentType())
+			w := httptest.NewRecorder()
+
+			rpc.ServeHTTP(w, req)
+
+			assertEqual(t, http.StatusOK, w.Code)
+
+			var o struct {
+				Output TestShipment `json:"output"`
+			}
+
+			assertNoError(t, codec.Decode(w.Body, &o, false))
+			assertEqual(t, "a", o.Output.Name)
+			assertEqual(t, true, shipped.Add(time.Hour).Equal(time.Time(o.Output.Shipped)))
+		})
+	}
+
+	t.Run("date input from javascript", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceSchedule{})
+
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceSchedule&method=Delay", strings.NewReader(`{"name": "a", "shipped": "2024-01-02T03:04:05.000Z"}`))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		assertEqual(t, http.StatusOK, w.Code)
+
+		o := MustUnmarshalJSON[struct {
+			Output TestShipment `json:"output"`
+		}](w.Body)
+
+		assertEqual(t, true, shipped.Add(time.Hour).Equal(time.Time(o.Output.Shipped)))
+	})
+
+	t.Run("accept", func(t *testing.T) {
+		for _, tt := range []struct {
+			request Codec
+			accept  string
+			want    Codec
+		}{
+			{JSONCodec, "application/cbor, application/json", CBORCodec},
+			{JSONCodec, "application/cbor;q=0.9, application/json", JSONCodec},
+			{JSONCodec, "application/json;q=0.1, application/msgpack", MessagePackCodec},
+			{JSONCodec, "application/msgpack; charset=utf-8; q=0.5, application/cbor;q=0.4", MessagePackCodec},
+			{JSONCodec, "application/cbor;q=0", JSONCodec},
+			{CBORCodec, "application/cbor;q=0", JSONCodec},
+			{CBORCodec, "application/cbor;q=0, text/html", JSONCodec},
+			{CBORCodec, "application/msgpack;q=0", CBORCodec},
+			{CBORCodec, "application/msgpack;q=2", CBORCodec},
+		} {
+			t.Run(tt.accept, func(t *testing.T) {
+				rpc := newTestServer()
+				rpc.Register(&TestServiceShipment{})
+
+				body, err := tt.request.Marshal(TestItem{Name: "a"})
+				assertNoError(t, err)
+
+				res := serve(rpc, "Ship", body, tt.request.ContentType(), tt.accept)
+				defer res.Body.Close()
+
+				assertEqual(t, http.StatusOK, res.StatusCode)
+				assertEqual(t, tt.want.ContentType(), res.Header.Get("Content-Type"))
+			})
+		}
+	})
+
+	t.Run("default json", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceShipment{})
+
+		res := serve(rpc, "Ship", []byte(`{"name": "a"}`), "text/plain;charset=UTF-8", "*/*")
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, JSONCodec.ContentType(), res.Header.Get("Content-Type"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceShipment{})
+
+		res := serve(rpc, "NotFound", nil, MessagePackCodec.ContentType(), "")
+		defer res.Body.Close()
+
+		var o errorResponse
+
+		assertNoError(t, MessagePackCodec.Decode(res.Body, &o, false))
+		assertEqual(t, http.StatusNotFound, res.StatusCode)
+		assertEqual(t, CodeNotFound, o.Code)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		rpc := newTestServer(WithStrictDecoding())
+		rpc.Register(&TestServiceShipment{})
+
+		body, err := MessagePackCodec.Marshal(map[string]string{"nmae": "a"})
+		assertNoError(t, err)
+
+		res := serve(rpc, "Ship", body, MessagePackCodec.ContentType(), "")
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
+
+func TestServerCompression(t *testing.T) {
+	serve := func(rpc *Server, url string, body io.Reader, header http.Header) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, url, body)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	compress := func(encoding string, data string) io.Reader {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+
+		if encoding == "gzip" {
+			w = gzip.NewWriter(&buf)
+		} else {
+			w = zlib.NewWriter(&buf)
+		}
+
+		w.Write([]byte(data))
+		w.Close()
+
+		return &buf
+	}
+
+	decompress := func(t *testing.T, res *http.Response) string {
+		var r io.Reader = res.Body
+		var err error
+
+		switch res.Header.Get("Content-Encoding") {
+		case "gzip":
+			r, err = gzip.NewReader(res.Body)
+		case "deflate":
+			r, err = zlib.NewReader(res.Body)
+		}
+
+		assertNoError(t, err)
+
+		b, err := io.ReadAll(r)
+		assertNoError(t, err)
+
+		return string(b)
+	}
+
+	const url = "/?service=TestServiceShipment&method=Ship"
+
+	for _, tt := range []struct {
+		name           string
+		minBytes       int
+		acceptEncoding string
+		encoding       string
+	}{
+		{"gzip", 0, "gzip, deflate", "gzip"},
+		{"deflate", 0, "gzip;q=0.5, deflate", "deflate"},
+		{"any", 0, "*", "gzip"},
+		{"any but gzip", 0, "gzip;q=0, *", "deflate"},
+		{"any refused", 0, "*;q=0", ""},
+		{"identity", 0, "identity", ""},
+		{"refused", 0, "gzip;q=0", ""},
+		{"below threshold", 1 << 20, "gzip", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rpc := newTestServer(WithCompression(tt.minBytes))
+			rpc.Register(&TestServiceShipment{})
+
+			res := serve(rpc, url, strings.NewReader(`{"name": "a"}`), http.Header{
+				"Accept-Encoding": {tt.acceptEncoding},
+			})
+			defer res.Body.Close()
+
+			assertEqual(t, http.StatusOK, res.StatusCode)
+			assertEqual(t, tt.encoding, res.Header.Get("Content-Encoding"))
+			assertEqual(t, "Accept-Encoding", res.Header.Get("Vary"))
+			assertEqual(t, true, strings.Contains(decompress(t, res), `"name":"a"`))
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceShipment{})
+
+		res := serve(rpc, url, strings.NewReader(`{"name": "a"}`), http.Header{
+			"Accept-Encoding": {"gzip"},
+		})
+		defer res.Body.Close()
+
+		assertEqual(t, "", res.Header.Get("Content-Encoding"))
+		assertEqual(t, "", res.Header.Get("Vary"))
+	})
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run("request "+encoding, func(t *testing.T) {
+			rpc := newTestServer()
+			rpc.Register(&TestServiceShipment{})
+
+			res := serve(rpc, url, compress(encoding, `{"name": "a"}`), http.Header{
+				"Content-Encoding": {encoding},
+			})
+			defer res.Body.Close()
+
+			assertEqual(t, http.StatusOK, res.StatusCode)
+			assertEqual(t, true, strings.Contains(decompress(t, res), `"name":"a"`))
+		})
+	}
+
+	t.Run("request unsupported", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceShipment{})
+
+		res := serve(rpc, url, strings.NewReader(`{"name": "a"}`), http.Header{
+			"Content-Encoding": {"br"},
+		})
+		defer res.Body.Close()
+
+		var o errorResponse
+
+		assertNoError(t, json.NewDecoder(res.Body).Decode(&o))
+		assertEqual(t, http.StatusUnsupportedMediaType, res.StatusCode)
+		assertEqual(t, CodeUnsupportedMediaType, o.Code)
+	})
+
+	t.Run("request corrupt", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestServiceShipment{})
+
+		body, _ := io.ReadAll(compress("gzip", `{"name": "a"}`))
+		body[len(body)-5] ^= 0xff
+
+		res := serve(rpc, url, bytes.NewReader(body), http.Header{
+			"Content-Encoding": {"gzip"},
+		})
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		rpc := newTestServer(WithCompression(0))
+		rpc.Register(&TestServiceShipment{})
+
+		res := serve(rpc, "/?batch", compress("gzip", `[{"service": "TestServiceShipment", "method": "Ship", "input": {"name": "a"}}]`), http.Header{
+			"Content-Encoding": {"gzip"},
+			"Accept-Encoding":  {"gzip"},
+		})
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, "gzip", res.Header.Get("Content-Encoding"))
+		assertEqual(t, true, strings.Contains(decompress(t, res), `"name":"a"`))
+	})
+}
+
+type TestServiceQuery struct{}
+
+func (c *TestServiceQuery) Find(ctx context.Context, name string) (TestItem, error) {
+	if name == "" {
+		return TestItem{}, errors.New("no name")
+	}
+
+	SetCacheControl(ctx, "public, max-age=60")
+
+	return TestItem{Name: name}, nil
+}
+
+func (c *TestServiceQuery) Count(ctx context.Context) (int, error) {
+	return 3, nil
+}
+
+func (c *TestServiceQuery) Update(ctx context.Context, name string) error {
+	return nil
+}
+
+func newTestQueryServer(options ...ServerOption) *Server {
+	rpc := newTestServer(options...)
+	rpc.MustRegister(&TestServiceQuery{}, WithMethod("Find", WithMethodQuery()), WithMethod("Count", WithMethodQuery()))
+
+	return rpc
+}
+
+func TestServerQuery(t *testing.T) {
+	serve := func(rpc *Server, query string, header http.Header) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/?service=TestServiceQuery&"+query, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	t.Run("get", func(t *testing.T) {
+		res := serve(newTestQueryServer(), "method=Find&input=%22a%20b%22", nil)
+		defer res.Body.Close()
+
+		var o struct {
+			Output TestItem `json:"output"`
+		}
+
+		assertNoError(t, json.NewDecoder(res.Body).Decode(&o))
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, "a b", o.Output.Name)
+		assertEqual(t, "public, max-age=60", res.Header.Get("Cache-Control"))
+		assertEqual(t, true, strings.HasPrefix(res.Header.Get("ETag"), `W/"`))
+		assertEqual(t, "Accept", res.Header.Get("Vary"))
+	})
+
+	t.Run("no input", func(t *testing.T) {
+		res := serve(newTestQueryServer(), "method=Count", nil)
+		defer res.Body.Close()
+
+		b, _ := io.ReadAll(res.Body)
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, `{"output":3}`, string(b))
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		rpc := newTestQueryServer()
+
+		res := serve(rpc, "method=Find&input=%22a%22", nil)
+		res.Body.Close()
+
+		etag := res.Header.Get("ETag")
+
+		for _, ifNoneMatch := range []string{etag, `"other", ` + strings.TrimPrefix(etag, "W/"), "*"} {
+			res = serve(rpc, "method=Find&input=%22a%22", http.Header{"If-None-Match": {ifNoneMatch}})
+			b, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+
+			assertEqual(t, http.StatusNotModified, res.StatusCode)
+			assertEqual(t, etag, res.Header.Get("ETag"))
+			assertEqual(t, "public, max-age=60", res.Header.Get("Cache-Control"))
+			assertEqual(t, 0, len(b))
+		}
+
+		res = serve(rpc, "method=Find&input=%22b%22", http.Header{"If-None-Match": {etag}})
+		res.Body.Close()
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		res := serve(newTestQueryServer(), "method=Find&input=%22%22", nil)
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusInternalServerError, res.StatusCode)
+		assertEqual(t, "", res.Header.Get("Cache-Control"))
+		assertEqual(t, "", res.Header.Get("ETag"))
+	})
+
+	t.Run("malformed input", func(t *testing.T) {
+		res := serve(newTestQueryServer(), "method=Find&input=a", nil)
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("not query", func(t *testing.T) {
+		res := serve(newTestQueryServer(), "method=Update&input=%22a%22", nil)
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusMethodNotAllowed, res.StatusCode)
+		assertEqual(t, http.MethodPost, res.Header.Get("Allow"))
+	})
+
+	t.Run("post", func(t *testing.T) {
+		rpc := newTestQueryServer()
+
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceQuery&method=Find", strings.NewReader(`"a"`))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		assertEqual(t, http.StatusOK, w.Code)
+		assertEqual(t, "", w.Header().Get("ETag"))
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		for _, s := range newTestQueryServer().metadata().Services {
+			for _, m := range s.Methods {
+				assertEqual(t, m.Name != "Update", m.Query)
+			}
+		}
+	})
+}
+
+type TestServiceSession struct{}
+
+func (c *TestServiceSession) Login(ctx context.Context, name string) error {
+	SetCookie(ctx, &http.Cookie{Name: "session", Value: name})
+	SetHeader(ctx, "X-Test", "login")
+
+	return nil
+}
+
+func (c *TestServiceSession) Whoami(ctx context.Context) (string, error) {
+	cookie, err := RequestFromContext(ctx).Cookie("session")
+
+	if err != nil {
+		return "", err
+	}
+
+	return cookie.Value, nil
+}
+
+func (c *TestServiceSession) Logout(ctx context.Context) error {
+	SetHeader(ctx, "X-Test", "logout")
+	SetCacheControl(ctx, "no-store")
+
+	return errors.New("not logged in")
+}
+
+func (c *TestServiceSession) Watch(ctx context.Context, stream *Stream[int]) error {
+	SetHeader(ctx, "X-Test", "watch")
+
+	return stream.Send(1)
+}
+
+func TestServerContext(t *testing.T) {
+	serve := func(rpc *Server, url string, body string, cookie *http.Cookie) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	rpc := newTestServer()
+	rpc.Register(&TestServiceSession{})
+
+	t.Run("set cookie", func(t *testing.T) {
+		res := serve(rpc, "/?service=TestServiceSession&method=Login", `"a"`, nil)
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, "login", res.Header.Get("X-Test"))
+		assertEqual(t, 1, len(res.Cookies()))
+		assertEqual(t, "a", res.Cookies()[0].Value)
+	})
+
+	t.Run("request", func(t *testing.T) {
+		res := serve(rpc, "/?service=TestServiceSession&method=Whoami", "", &http.Cookie{Name: "session", Value: "a"})
+		defer res.Body.Close()
+
+		b, _ := io.ReadAll(res.Body)
+
+		assertEqual(t, `{"output":"a"}`, string(b))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		res := serve(rpc, "/?service=TestServiceSession&method=Logout", "", nil)
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusInternalServerError, res.StatusCode)
+		assertEqual(t, "logout", res.Header.Get("X-Test"))
+		assertEqual(t, "", res.Header.Get("Cache-Control"))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		res := serve(rpc, "/?service=TestServiceSession&method=Watch", "", nil)
+		defer res.Body.Close()
+
+		assertEqual(t, "watch", res.Header.Get("X-Test"))
+		assertEqual(t, "text/event-stream", res.Header.Get("Content-Type"))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		res := serve(rpc, "/?batch", `[{"service": "TestServiceSession", "method": "Whoami"}, {"service": "TestServiceSession", "method": "Login", "input": "b"}]`, &http.Cookie{Name: "session", Value: "a"})
+		defer res.Body.Close()
+
+		b, _ := io.ReadAll(res.Body)
+
+		assertEqual(t, `[{"output":"a"},{"output":null}]`, string(b))
+		assertEqual(t, "", res.Header.Get("X-Test"))
+		assertEqual(t, 0, len(res.Cookies()))
+	})
+
+	t.Run("no request", func(t *testing.T) {
+		ctx := context.Background()
+
+		SetHeader(ctx, "X-Test", "a")
+		SetCookie(ctx, &http.Cookie{Name: "a", Value: "b"})
+		SetCacheControl(ctx, "no-store")
+
+		assertEqual(t, true, RequestFromContext(ctx) == nil)
+	})
+}
+
+type TestServiceSlow struct{}
+
+func (c *TestServiceSlow) Wait(ctx context.Context, ms int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return nil
+	}
+}
+
+func (c *TestServiceSlow) Watch(ctx context.Context, ms int, stream *Stream[int]) error {
+	if err := c.Wait(ctx, ms); err != nil {
+		return err
+	}
+
+	return stream.Send(1)
+}
+
+func TestServerTimeout(t *testing.T) {
+	serve := func(rpc *Server, method string, ms int, timeout string) (int, errorResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceSlow&method="+method, strings.NewReader(fmt.Sprint(ms)))
+		if timeout != "" {
+			req.Header.Set("X-Timeout-Ms", timeout)
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		var o errorResponse
+		json.NewDecoder(w.Body).Decode(&o)
+
+		return w.Code, o
+	}
+
+	for _, tt := range []struct {
+		name    string
+		server  []ServerOption
+		service []ServiceOption
+		method  string
+		ms      int
+		timeout string
+		status  int
+	}{
+		{"server", []ServerOption{WithTimeout(10 * time.Millisecond)}, nil, "Wait", 1000, "", http.StatusGatewayTimeout},
+		{"in time", []ServerOption{WithTimeout(time.Second)}, nil, "Wait", 1, "", http.StatusOK},
+		{"method", nil, []ServiceOption{WithMethod("Wait", WithMethodTimeout(10*time.Millisecond))}, "Wait", 1000, "", http.StatusGatewayTimeout},
+		{"method no timeout", []ServerOption{WithTimeout(time.Millisecond)}, []ServiceOption{WithMethod("Wait", WithMethodTimeout(0))}, "Wait", 20, "", http.StatusOK},
+		{"client", nil, nil, "Wait", 1000, "10", http.StatusGatewayTimeout},
+		{"client shorter", []ServerOption{WithTimeout(time.Second)}, nil, "Wait", 1000, "10", http.StatusGatewayTimeout},
+		{"client longer", []ServerOption{WithTimeout(10 * time.Millisecond)}, nil, "Wait", 1000, "5000", http.StatusGatewayTimeout},
+		{"client invalid", nil, nil, "Wait", 1, "soon", http.StatusOK},
+		{"client huge", nil, nil, "Wait", 1, "10000000000000", http.StatusOK},
+		{"client max", nil, nil, "Wait", 1, "9223372036854775807", http.StatusOK},
+		{"stream", []ServerOption{WithTimeout(time.Millisecond)}, nil, "Watch", 20, "", http.StatusOK},
+		{"stream method", nil, []ServiceOption{WithMethod("Watch", WithMethodTimeout(10*time.Millisecond))}, "Watch", 1000, "", http.StatusGatewayTimeout},
+		{"stream client", nil, nil, "Watch", 1000, "10", http.StatusGatewayTimeout},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rpc := newTestServer(tt.server...)
+			rpc.MustRegister(&TestServiceSlow{}, tt.service...)
+
+			status, o := serve(rpc, tt.method, tt.ms, tt.timeout)
+
+			assertEqual(t, tt.status, status)
+
+			if tt.status == http.StatusGatewayTimeout {
+				assertEqual(t, CodeTimeout, o.Code)
+			}
+		})
+	}
+
+	t.Run("websocket", func(t *testing.T) {
+		rpc := newTestServer(WithWebSocket())
+		rpc.Register(&TestServiceSlow{})
+
+		server := httptest.NewServer(rpc)
+		defer server.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		assertNoError(t, err)
+		defer conn.Close()
+
+		var version wsResponse
+		assertNoError(t, conn.ReadJSON(&version))
+
+		assertNoError(t, conn.WriteJSON(map[string]any{"id": 1, "service": "TestServiceSlow", "method": "Wait", "input": 1000, "timeoutMs": 10}))
+
+		var resp struct {
+			ID     uint64 `json:"id"`
+			Status int    `json:"status"`
+			Code   string `json:"code"`
+		}
+		assertNoError(t, conn.ReadJSON(&resp))
+
+		assertEqual(t, uint64(1), resp.ID)
+		assertEqual(t, http.StatusGatewayTimeout, resp.Status)
+		assertEqual(t, CodeTimeout, resp.Code)
+	})
+}
+
+type TestServiceBusy struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newTestServiceBusy() *TestServiceBusy {
+	return &TestServiceBusy{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (c *TestServiceBusy) Block(ctx context.Context) error {
+	c.started <- struct{}{}
+
+	select {
+	case <-c.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *TestServiceBusy) Fast(ctx context.Context) error {
+	return nil
+}
+
+func TestServerConcurrencyLimit(t *testing.T) {
+	serve := func(rpc *Server, service, method string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/?service="+service+"&method="+method, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// block starts a call to Block and waits for it to run.
+	block := func(rpc *Server, svc *TestServiceBusy) chan int {
+		done := make(chan int, 1)
+
+		go func() {
+			done <- serve(rpc, "TestServiceBusy", "Block", nil).Code
+		}()
+
+		<-svc.started
+
+		return done
+	}
+
+	assertShed := func(t *testing.T, w *httptest.ResponseRecorder) {
+		t.Helper()
+
+		var o errorResponse
+		assertNoError(t, json.NewDecoder(w.Body).Decode(&o))
+
+		assertEqual(t, http.StatusServiceUnavailable, w.Code)
+		assertEqual(t, "1", w.Header().Get("Retry-After"))
+		assertEqual(t, CodeUnavailable, o.Code)
+	}
+
+	t.Run("method", func(t *testing.T) {
+		svc := newTestServiceBusy()
+		rpc := newTestServer()
+		rpc.MustRegister(svc, WithMethod("Block", WithMethodConcurrencyLimit(1, 0)))
+
+		done := block(rpc, svc)
+
+		assertShed(t, serve(rpc, "TestServiceBusy", "Block", nil))
+		assertEqual(t, http.StatusOK, serve(rpc, "TestServiceBusy", "Fast", nil).Code)
+
+		close(svc.release)
+		assertEqual(t, http.StatusOK, <-done)
+		assertEqual(t, http.StatusOK, serve(rpc, "TestServiceBusy", "Block", nil).Code)
+	})
+
+	t.Run("service", func(t *testing.T) {
+		svc := newTestServiceBusy()
+		rpc := newTestServer()
+		rpc.MustRegister(svc, WithServiceConcurrencyLimit(1, 0))
+		rpc.MustRegister(&TestService1{})
+
+		done := block(rpc, svc)
+
+		assertShed(t, serve(rpc, "TestServiceBusy", "Fast", nil))
+		assertEqual(t, http.StatusOK, serve(rpc, "TestService1", "One", nil).Code)
+
+		close(svc.release)
+		assertEqual(t, http.StatusOK, <-done)
+	})
+
+	t.Run("server", func(t *testing.T) {
+		svc := newTestServiceBusy()
+		rpc := newTestServer(WithConcurrencyLimit(1, 0))
+		rpc.MustRegister(svc)
+		rpc.MustRegister(&TestService1{})
+
+		done := block(rpc, svc)
+
+		assertShed(t, serve(rpc, "TestService1", "One", nil))
+
+		close(svc.release)
+		assertEqual(t, http.StatusOK, <-done)
+		assertEqual(t, http.StatusOK, serve(rpc, "TestService1", "One", nil).Code)
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		svc := newTestServiceBusy()
+		rpc := newTestServer()
+		rpc.MustRegister(svc, WithMethod("Block", WithMethodConcurrencyLimit(1, 1)))
+
+		l := rpc.services["TestServiceBusy"].methods["Block"].limiter
+		done := block(rpc, svc)
+
+		queued := make(chan int, 1)
+		go func() {
+			queued <- serve(rpc, "TestServiceBusy", "Block", nil).Code
+		}()
+
+		for {
+			l.mu.Lock()
+			n := l.queued
+			l.mu.Unlock()
+
+			if n == 1 {
+				break
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+
+		assertShed(t, serve(rpc, "TestServiceBusy", "Block", nil))
+
+		close(svc.release)
+		assertEqual(t, http.StatusOK, <-done)
+		assertEqual(t, http.StatusOK, <-queued)
+	})
+
+	t.Run("queue timeout", func(t *testing.T) {
+		svc := newTestServiceBusy()
+		rpc := newTestServer()
+		rpc.MustRegister(svc, WithMethod("Block", WithMethodConcurrencyLimit(1, 1)))
+
+		done := block(rpc, svc)
+
+		w := serve(rpc, "TestServiceBusy", "Block", http.Header{"X-Timeout-Ms": {"10"}})
+
+		assertEqual(t, http.StatusGatewayTimeout, w.Code)
+		assertEqual(t, "", w.Header().Get("Retry-After"))
+
+		close(svc.release)
+		assertEqual(t, http.StatusOK, <-done)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		svc := newTestServiceBusy()
+		rpc := newTestServer(WithConcurrencyLimit(0, 0))
+		rpc.MustRegister(svc, WithServiceConcurrencyLimit(-1, 0), WithMethod("Block", WithMethodConcurrencyLimit(0, 0)))
+
+		done := block(rpc, svc)
+
+		assertEqual(t, http.StatusOK, serve(rpc, "TestServiceBusy", "Fast", nil).Code)
+
+		close(svc.release)
+		assertEqual(t, http.StatusOK, <-done)
+		assertEqual(t, http.StatusOK, serve(rpc, "TestServiceBusy", "Block", nil).Code)
+	})
+
+	t.Run("error filter", func(t *testing.T) {
+		svc := newTestServiceBusy()
+		rpc := newTestServer(WithConcurrencyLimit(1, 0), WithErrorFilter(func(err error) error { return nil }))
+		rpc.MustRegister(svc)
+
+		done := block(rpc, svc)
+
+		assertShed(t, serve(rpc, "TestServiceBusy", "Fast", nil))
+
+		close(svc.release)
+		<-done
+	})
+}
+
+func TestServerRateLimit(t *testing.T) {
+	serve := func(rpc *Server, method, remoteAddr, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestService1&method="+method, strings.NewReader("0"))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("limited", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.MustRegister(&TestService1{}, WithMethod("Three", WithMethodRateLimit(2, time.Minute)))
+
+		assertEqual(t, http.StatusOK, serve(rpc, "Three", "10.0.0.1:1234", "").Code)
+		assertEqual(t, http.StatusOK, serve(rpc, "Three", "10.0.0.1:1235", "").Code)
+
+		w := serve(rpc, "Three", "10.0.0.1:1236", "")
+
+		var o struct {
+			Code    string         `json:"code"`
+			Details RateLimitError `json:"details"`
+		}
+		assertNoError(t, json.NewDecoder(w.Body).Decode(&o))
+
+		assertEqual(t, http.StatusTooManyRequests, w.Code)
+		assertEqual(t, "30", w.Header().Get("Retry-After"))
+		assertEqual(t, CodeRateLimited, o.Code)
+		assertEqual(t, true, o.Details.RetryAfterMs > 29000 && o.Details.RetryAfterMs <= 30000)
+
+		assertEqual(t, http.StatusOK, serve(rpc, "Three", "10.0.0.2:1234", "").Code)
+		assertEqual(t, http.StatusOK, serve(rpc, "One", "10.0.0.1:1234", "").Code)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		for _, option := range []MethodOption{WithMethodRateLimit(0, time.Minute), WithMethodRateLimit(-1, time.Minute), WithMethodRateLimit(1, 0)} {
+			rpc := newTestServer()
+			rpc.MustRegister(&TestService1{}, WithMethod("Three", option))
+
+			for i := 0; i < 3; i++ {
+				assertEqual(t, http.StatusOK, serve(rpc, "Three", "10.0.0.1:1234", "").Code)
+			}
+		}
+	})
+
+	t.Run("key", func(t *testing.T) {
+		rpc := newTestServer(WithRateLimitKey(func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		}))
+		rpc.MustRegister(&TestService1{}, WithMethod("Three", WithMethodRateLimit(1, time.Minute)))
+
+		assertEqual(t, http.StatusOK, serve(rpc, "Three", "10.0.0.1:1234", "a").Code)
+		assertEqual(t, http.StatusTooManyRequests, serve(rpc, "Three", "10.0.0.2:1234", "a").Code)
+		assertEqual(t, http.StatusOK, serve(rpc, "Three", "10.0.0.1:1234", "b").Code)
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		l := &rateLimiter{n: 2, per: time.Second, buckets: make(map[string]*bucket)}
+		now := time.Now()
+
+		_, ok := l.take("a", now)
+		assertEqual(t, true, ok)
+		_, ok = l.take("a", now)
+		assertEqual(t, true, ok)
+
+		wait, ok := l.take("a", now)
+		assertEqual(t, false, ok)
+		assertEqual(t, 500*time.Millisecond, wait)
+
+		_, ok = l.take("a", now.Add(500*time.Millisecond))
+		assertEqual(t, true, ok)
+
+		_, ok = l.take("b", now.Add(2*time.Second))
+		assertEqual(t, true, ok)
+		assertEqual(t, 1, len(l.buckets))
+	})
+}
+
+type testUser struct {
+	name  string
+	roles []string
+}
+
+func (u testUser) HasRole(role string) bool {
+	for _, r := range u.roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// testAuthenticator authenticates requests with an "Authorization: name
+// role..." header.
+func testAuthenticator(r *http.Request) (any, error) {
+	auth := r.Header.Get("Authorization")
+
+	if auth == "" {
+		return nil, nil
+	}
+
+	if auth == "invalid" {
+		return nil, errors.New("invalid credentials")
+	}
+
+	fields := strings.Fields(auth)
+
+	return testUser{name: fields[0], roles: fields[1:]}, nil
+}
+
+type TestServiceAdmin struct{}
+
+func (c *TestServiceAdmin) Public(ctx context.Context) error {
+	return nil
+}
+
+func (c *TestServiceAdmin) Whoami(ctx context.Context) (string, error) {
+	return PrincipalFromContext(ctx).(testUser).name, nil
+}
+
+func (c *TestServiceAdmin) Delete(ctx context.Context) error {
+	return nil
+}
+
+func TestServerAuth(t *testing.T) {
+	serve := func(rpc *Server, url, auth string, body string) (int, errorResponse) {
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		var o errorResponse
+		json.NewDecoder(w.Body).Decode(&o)
+
+		return w.Code, o
+	}
+
+	newServer := func(options ...ServiceOption) *Server {
+		rpc := newTestServer(WithAuthenticator(testAuthenticator), WithErrorFilter(func(err error) error { return nil }))
+		rpc.MustRegister(&TestServiceAdmin{}, append([]ServiceOption{
+			WithMethod("Whoami", WithMethodPolicy(Authenticated())),
+			WithMethod("Delete", WithMethodPolicy(RequireRole("admin", "owner"))),
+		}, options...)...)
+
+		return rpc
+	}
+
+	for _, tt := range []struct {
+		method string
+		auth   string
+		status int
+		code   string
+	}{
+		{"Public", "", http.StatusOK, ""},
+		{"Public", "invalid", http.StatusUnauthorized, CodeUnauthenticated},
+		{"Whoami", "", http.StatusUnauthorized, CodeUnauthenticated},
+		{"Whoami", "a", http.StatusOK, ""},
+		{"Delete", "", http.StatusUnauthorized, CodeUnauthenticated},
+		{"Delete", "a user", http.StatusForbidden, CodeForbidden},
+		{"Delete", "a owner", http.StatusOK, ""},
+	} {
+		t.Run(tt.method+" "+tt.auth, func(t *testing.T) {
+			status, o := serve(newServer(), "/?service=TestServiceAdmin&method="+tt.method, tt.auth, "")
+
+			assertEqual(t, tt.status, status)
+			assertEqual(t, tt.code, o.Code)
+		})
+	}
+
+	t.Run("authenticator error", func(t *testing.T) {
+		for _, tt := range []struct {
+			name    string
+			err     error
+			status  int
+			code    string
+			message string
+		}{
+			{"error", errors.New("invalid signature for user a"), http.StatusUnauthorized, CodeUnauthenticated, "unauthenticated"},
+			{"rpc error", &RPCError{Code: "token_expired", Status: http.StatusUnauthorized, Message: "token expired"}, http.StatusUnauthorized, "token_expired", "token expired"},
+			{"status error", teapotError{}, http.StatusTeapot, CodeBadRequest, "teapot"},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				rpc := newTestServer(WithAuthenticator(func(r *http.Request) (any, error) {
+					return nil, tt.err
+				}))
+				rpc.MustRegister(&TestServiceAdmin{})
+
+				status, o := serve(rpc, "/?service=TestServiceAdmin&method=Public", "", "")
+
+				assertEqual(t, tt.status, status)
+				assertEqual(t, tt.code, o.Code)
+				assertEqual(t, tt.message, o.Message)
+			})
+		}
+	})
+
+	t.Run("principal", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestServiceAdmin&method=Whoami", nil)
+		req.Header.Set("Authorization", "a")
+		w := httptest.NewRecorder()
+
+		newServer().ServeHTTP(w, req)
+
+		assertEqual(t, `{"output":"a"}`, w.Body.String())
+	})
+
+	t.Run("service policy", func(t *testing.T) {
+		rpc := newServer(WithServicePolicy(RequireRole("admin")))
+
+		status, _ := serve(rpc, "/?service=TestServiceAdmin&method=Public", "a owner", "")
+		assertEqual(t, http.StatusForbidden, status)
+
+		status, _ = serve(rpc, "/?service=TestServiceAdmin&method=Public", "a admin", "")
+		assertEqual(t, http.StatusOK, status)
+	})
+
+	t.Run("custom policy", func(t *testing.T) {
+		rpc := newServer(WithMethod("Public", WithMethodPolicy(func(ctx context.Context, principal any) error {
+			return errors.New("closed")
+		})))
+
+		status, o := serve(rpc, "/?service=TestServiceAdmin&method=Public", "", "")
+
+		assertEqual(t, http.StatusForbidden, status)
+		assertEqual(t, "forbidden: closed", o.Message)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/?batch", strings.NewReader(`[{"service": "TestServiceAdmin", "method": "Whoami"}, {"service": "TestServiceAdmin", "method": "Delete"}]`))
+		req.Header.Set("Authorization", "a")
+		w := httptest.NewRecorder()
+
+		newServer().ServeHTTP(w, req)
+
+		results := MustUnmarshalJSON[[]struct {
+			Output json.RawMessage `json:"output"`
+			Status int             `json:"status"`
+		}](w.Body)
+
+		assertEqual(t, `"a"`, string(results[0].Output))
+		assertEqual(t, http.StatusForbidden, results[1].Status)
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		rpc := newTestServer(WithWebSocket(), WithAuthenticator(testAuthenticator))
+		rpc.MustRegister(&TestServiceAdmin{})
+
+		server := httptest.NewServer(rpc)
+		defer server.Close()
+
+		_, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Authorization": {"invalid"}})
+
+		assertEqual(t, true, err != nil)
+		assertEqual(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
+
+func TestServerCORS(t *testing.T) {
+	serve := func(rpc *Server, method string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/?service=TestService1&method=Three", strings.NewReader("0"))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w
+	}
+
+	preflight := http.Header{
+		"Origin":                         {"https://app.example.com"},
+		"Access-Control-Request-Method":  {"POST"},
+		"Access-Control-Request-Headers": {"content-type, x-timeout-ms"},
+	}
+
+	newServer := func(cors CORS) *Server {
+		rpc := newTestServer(WithCORS(cors))
+		rpc.Register(&TestService1{})
+
+		return rpc
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		w := serve(newServer(CORS{AllowedOrigins: []string{"https://app.example.com"}, AllowedHeaders: []string{"X-Tenant"}, MaxAge: time.Hour}), http.MethodOptions, preflight)
+
+		assertEqual(t, http.StatusNoContent, w.Code)
+		assertEqual(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assertEqual(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+		assertEqual(t, true, strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Content-Type"))
+		assertEqual(t, true, strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "X-Timeout-Ms"))
+		assertEqual(t, true, strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "X-Tenant"))
+		assertEqual(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+		assertEqual(t, "", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("preflight disallowed origin", func(t *testing.T) {
+		w := serve(newServer(CORS{AllowedOrigins: []string{"https://other.example.com"}}), http.MethodOptions, preflight)
+
+		assertEqual(t, http.StatusNoContent, w.Code)
+		assertEqual(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("call", func(t *testing.T) {
+		w := serve(newServer(CORS{AllowedOrigins: []string{"*"}}), http.MethodPost, http.Header{"Origin": {"https://app.example.com"}})
+
+		assertEqual(t, http.StatusOK, w.Code)
+		assertEqual(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assertEqual(t, true, strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-Server-Version"))
+		assertEqual(t, "Origin", w.Header().Get("Vary"))
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		w := serve(newServer(CORS{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}), http.MethodPost, http.Header{"Origin": {"https://app.example.com"}})
+
+		assertEqual(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assertEqual(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("any origin credentials", func(t *testing.T) {
+		w := serve(newServer(CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}), http.MethodPost, http.Header{"Origin": {"https://app.example.com"}})
+
+		assertEqual(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assertEqual(t, "", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		w := serve(newServer(CORS{AllowedOrigins: []string{"https://other.example.com"}}), http.MethodPost, http.Header{"Origin": {"https://app.example.com"}})
+
+		assertEqual(t, http.StatusOK, w.Code)
+		assertEqual(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("no cors", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestService1{})
+
+		w := serve(rpc, http.MethodOptions, preflight)
+
+		assertEqual(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		rpc := newTestServer(WithCORS(CORS{AllowedOrigins: []string{"https://app.example.com"}}), WithWebSocket())
+		rpc.Register(&TestService1{})
+
+		server := httptest.NewServer(rpc)
+		defer server.Close()
+
+		url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://app.example.com"}})
+		assertNoError(t, err)
+		conn.Close()
+
+		conn, _, err = websocket.DefaultDialer.Dial(url, http.Header{"Origin": {server.URL}})
+		assertNoError(t, err)
+		conn.Close()
+
+		_, res, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://other.example.com"}})
+		assertEqual(t, true, err != nil)
+		assertEqual(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestServerCSRFProtection(t *testing.T) {
+	serve := func(rpc *Server, method, url string, header http.Header) int {
+		req := httptest.NewRequest(method, url, strings.NewReader("0"))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	rpc := newTestServer(WithCSRFProtection())
+	rpc.MustRegister(&TestService1{}, WithMethod("Three", WithMethodQuery()))
+
+	const url = "/?service=TestService1&method=Three"
+
+	for _, tt := range []struct {
+		name   string
+		header http.Header
+		status int
+	}{
+		{"no content type", nil, http.StatusForbidden},
+		{"plain text", http.Header{"Content-Type": {"text/plain;charset=UTF-8"}}, http.StatusForbidden},
+		{"form", http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, http.StatusForbidden},
+		{"multipart", http.Header{"Content-Type": {"multipart/form-data; boundary=x"}}, http.StatusForbidden},
+		{"json", http.Header{"Content-Type": {"application/json"}}, http.StatusOK},
+		{"requested with", http.Header{"Content-Type": {"text/plain"}, "X-Requested-With": {"XMLHttpRequest"}}, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assertEqual(t, tt.status, serve(rpc, http.MethodPost, url, tt.header))
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		assertEqual(t, http.StatusForbidden, serve(rpc, http.MethodPost, "/?batch", http.Header{"Content-Type": {"text/plain"}}))
+	})
+
+	t.Run("query", func(t *testing.T) {
+		assertEqual(t, http.StatusOK, serve(rpc, http.MethodGet, url+"&input=0", nil))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		rpc := newTestServer()
+		rpc.Register(&TestService1{})
+
+		assertEqual(t, http.StatusOK, serve(rpc, http.MethodPost, url, http.Header{"Content-Type": {"text/plain"}}))
+	})
+}
+
+func TestServerOptions(t *testing.T) {
+	t.Run("serve javascript client", func(t *testing.T) {
+		rpc := newTestServer(WithServerJavaScriptClient())
+
+		rpc.Register(&TestService1{})
+		rpc.Register(&TestService2{})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		jsClient, err := io.ReadAll(res.Body)
+
+		assertNoError(t, err)
+
+		assertEqual(t, rpc.JavaScriptClient(), string(jsClient))
+	})
+
+	t.Run("not serve javascript client", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestService1{})
+		rpc.Register(&TestService2{})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("logger", func(t *testing.T) {
+		rpc := newTestServer()
+
+		var called bool
+		rpc.methodLogger = makeMethodLogger(func(format string, a ...any) (n int, err error) {
+			called = true
+			return 0, nil
+		})
+
+		rpc.Register(&TestService1{})
+
+		assertEqual(t, called, true)
+	})
+}
+
+func TestServerInterceptors(t *testing.T) {
+	t.Run("call info and input", func(t *testing.T) {
+		var info CallInfo
+		var input any
+
+		rpc := newTestServer(WithInterceptor(func(ctx context.Context, i CallInfo, in any, next Invoker) (any, error) {
+			info, input = i, in
+			return next(ctx, in)
+		}))
+
+		rpc.Register(&TestServiceEcho{})
+
+		output := callRpc[string](rpc, "TestServiceEcho", "Echo", "Hello World!")
+
+		assertEqual(t, "Hello World!", output)
+		assertEqual(t, "TestServiceEcho", info.Service)
+		assertEqual(t, "Echo", info.Method)
+		assertEqual(t, "Hello World!", input.(string))
+	})
+
+	t.Run("order", func(t *testing.T) {
+		var order string
+
+		intercept := func(name string) Interceptor {
+			return func(ctx context.Context, info CallInfo, input any, next Invoker) (any, error) {
+				order += name
+				return next(ctx, input)
+			}
+		}
+
+		rpc := newTestServer(WithInterceptor(intercept("a")), WithInterceptor(intercept("b")))
+
+		rpc.Register(&TestService1{}, WithServiceInterceptor(intercept("c")))
+		rpc.Register(&TestService2{})
+
+		callRpc[int](rpc, "TestService1", "Three", 0)
+
+		assertEqual(t, "abc", order)
+
+		order = ""
+
+		callRpc[int](rpc, "TestService2", "Three", 0)
+
+		assertEqual(t, "ab", order)
+	})
+
+	t.Run("replace output", func(t *testing.T) {
+		rpc := newTestServer(WithInterceptor(func(ctx context.Context, info CallInfo, input any, next Invoker) (any, error) {
+			if _, err := next(ctx, input); err != nil {
+				return nil, err
+			}
+
+			return 42, nil
+		}))
+
+		rpc.Register(&TestService1{})
+
+		assertEqual(t, 42, callRpc[int](rpc, "TestService1", "Three", 0))
+	})
+
+	t.Run("short circuit", func(t *testing.T) {
+		rpc := newTestServer(WithInterceptor(func(ctx context.Context, info CallInfo, input any, next Invoker) (any, error) {
+			return nil, errTest
+		}))
+
+		rpc.Register(&TestService1{})
+
+		req := httptest.NewRequest(http.MethodPost, "/?service=TestService1&method=Three", strings.NewReader("0"))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		o := MustUnmarshalJSON[errorResponse](res.Body)
+
+		assertEqual(t, errTest.Error(), o.Message)
+	})
+}
+
+func TestServerBatch(t *testing.T) {
+	t.Run("results in order", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestService1{})
+		rpc.Register(&TestServiceEcho{})
+
+		calls := []map[string]any{
+			{"service": "TestService1", "method": "Three", "input": 0},
+			{"service": "TestServiceEcho", "method": "Echo", "input": "Hello World!"},
+			{"service": "TestService1", "method": "One"},
+			{"service": "TestService1", "method": "Error", "input": "an error"},
+			{"service": "NotFound", "method": "Test"},
+			{"method": "Test"},
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/?batch", MustMarshalJSON(calls))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, rpc.version, res.Header.Get("X-Server-Version"))
+
+		results := MustUnmarshalJSON[[]struct {
+			Output  json.RawMessage `json:"output"`
+			Status  int             `json:"status"`
+			Message string          `json:"message"`
+		}](res.Body)
+
+		assertEqual(t, len(calls), len(results))
+		assertEqual(t, "3", string(results[0].Output))
+		assertEqual(t, `"Hello World!"`, string(results[1].Output))
+		assertEqual(t, "null", string(results[2].Output))
+		assertEqual(t, http.StatusInternalServerError, results[3].Status)
+		assertEqual(t, "an error", results[3].Message)
+		assertEqual(t, http.StatusNotFound, results[4].Status)
+		assertEqual(t, http.StatusBadRequest, results[5].Status)
+	})
+
+	t.Run("malformed batch", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestService1{})
+
+		req := httptest.NewRequest(http.MethodPost, "/?batch", strings.NewReader("malformed"))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("too many calls", func(t *testing.T) {
+		calls := make([]map[string]any, 3)
+
+		for i := range calls {
+			calls[i] = map[string]any{"service": "TestService1", "method": "Three", "input": 0}
+		}
+
+		for _, tt := range []struct {
+			options []ServerOption
+			status  int
+		}{
+			{[]ServerOption{WithMaxBatchSize(2)}, http.StatusRequestEntityTooLarge},
+			{[]ServerOption{WithMaxBatchSize(3)}, http.StatusOK},
+			{[]ServerOption{WithMaxBatchSize(0)}, http.StatusOK},
+		} {
+			rpc := newTestServer(tt.options...)
+			rpc.Register(&TestService1{})
+
+			req := httptest.NewRequest(http.MethodPost, "/?batch", MustMarshalJSON(calls))
+			w := httptest.NewRecorder()
+
+			rpc.ServeHTTP(w, req)
+
+			assertEqual(t, tt.status, w.Code)
+		}
+
+		calls = make([]map[string]any, defaultMaxBatchSize+1)
+
+		for i := range calls {
+			calls[i] = map[string]any{"service": "TestService1", "method": "Three", "input": 0}
+		}
+
+		rpc := newTestServer()
+		rpc.Register(&TestService1{})
+
+		req := httptest.NewRequest(http.MethodPost, "/?batch", MustMarshalJSON(calls))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		assertEqual(t, http.StatusRequestEntityTooLarge, w.Code)
+		assertEqual(t, CodePayloadTooLarge, MustUnmarshalJSON[errorResponse](w.Body).Code)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		var running, peak int32
+
+		rpc := newTestServer(WithInterceptor(func(ctx context.Context, info CallInfo, input any, next Invoker) (any, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				m := atomic.LoadInt32(&peak)
+
+				if n <= m || atomic.CompareAndSwapInt32(&peak, m, n) {
+					break
+				}
+			}
+
+			return next(ctx, input)
+		}))
+		rpc.Register(&TestServiceSlow{})
+
+		calls := make([]map[string]any, 3*batchConcurrency)
+
+		for i := range calls {
+			calls[i] = map[string]any{"service": "TestServiceSlow", "method": "Wait", "input": 5}
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/?batch", MustMarshalJSON(calls))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		assertEqual(t, http.StatusOK, w.Code)
+		assertEqual(t, true, atomic.LoadInt32(&peak) > 1)
+		assertEqual(t, true, atomic.LoadInt32(&peak) <= batchConcurrency)
+	})
+}
+
+type TestServiceStream struct{}
+
+func (c *TestServiceStream) Count(ctx context.Context, n int, stream *Stream[int]) error {
+	for i := 0; i < n; i++ {
+		if err := stream.Send(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *TestServiceStream) Fail(ctx context.Context, n int, stream *Stream[int]) error {
+	for i := 0; i < n; i++ {
+		if err := stream.Send(i); err != nil {
+			return err
+		}
+	}
+
+	return errTest
+}
+
+func (c *TestServiceStream) Empty(ctx context.Context, stream *Stream[string]) error {
+	return nil
+}
+
+func TestServerStream(t *testing.T) {
+	stream := func(rpc *Server, method string, input string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/?service=TestServiceStream&method=%s", method), strings.NewReader(input))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	t.Run("events", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestServiceStream{})
+
+		res := stream(rpc, "Count", "3")
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+
+		assertNoError(t, err)
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, "text/event-stream", res.Header.Get("Content-Type"))
+		assertEqual(t, "data: 0\n\ndata: 1\n\ndata: 2\n\nevent: end\ndata: null\n\n", string(body))
+	})
+
+	t.Run("no input", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestServiceStream{})
+
+		res := stream(rpc, "Empty", "")
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+
+		assertNoError(t, err)
+		assertEqual(t, "event: end\ndata: null\n\n", string(body))
+	})
+
+	t.Run("error event", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestServiceStream{})
+
+		res := stream(rpc, "Fail", "1")
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+
+		assertNoError(t, err)
+		assertEqual(t, http.StatusOK, res.StatusCode)
+		assertEqual(t, true, strings.HasSuffix(string(body), "event: error\ndata: {\"status\":500,\"code\":\"internal\",\"message\":\"test\"}\n\n"))
+	})
+
+	t.Run("error before send", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestServiceStream{})
+
+		res := stream(rpc, "Fail", "0")
+		defer res.Body.Close()
+
+		assertEqual(t, http.StatusInternalServerError, res.StatusCode)
+
+		o := MustUnmarshalJSON[errorResponse](res.Body)
+
+		assertEqual(t, errTest.Error(), o.Message)
+	})
+
+	t.Run("not in batch", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestServiceStream{})
+
+		req := httptest.NewRequest(http.MethodPost, "/?batch", strings.NewReader(`[{"service": "TestServiceStream", "method": "Count", "input": 1}]`))
+		w := httptest.NewRecorder()
+
+		rpc.ServeHTTP(w, req)
+
+		results := MustUnmarshalJSON[[]errorResponse](w.Result().Body)
+
+		assertEqual(t, http.StatusBadRequest, results[0].Status)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestServiceStream{})
+
+		md := rpc.metadata().Services[0].Methods[0]
+
+		assertEqual(t, "Count", md.Name)
+		assertEqual(t, true, md.Stream)
+		assertEqual(t, "int", md.Output.String())
+	})
+}
+
+type TestServiceBlock struct {
+	canceled chan struct{}
+}
+
+func (c *TestServiceBlock) Block(ctx context.Context) error {
+	<-ctx.Done()
+	close(c.canceled)
+
+	return ctx.Err()
+}
+
+func TestServerWebSocket(t *testing.T) {
+	dial := func(t *testing.T, rpc *Server) *websocket.Conn {
+		server := httptest.NewServer(rpc)
+		t.Cleanup(server.Close)
+
+		conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		assertNoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		assertEqual(t, rpc.version, res.Header.Get("X-Server-Version"))
+
+		var hello wsResponse
+		assertNoError(t, conn.ReadJSON(&hello))
+		assertEqual(t, rpc.version, hello.Version)
+
+		return conn
+	}
+
+	t.Run("call", func(t *testing.T) {
+		rpc := newTestServer(WithWebSocket())
+
+		rpc.Register(&TestService1{})
+
+		conn := dial(t, rpc)
+
+		assertNoError(t, conn.WriteJSON(map[string]any{"id": 1, "service": "TestService1", "method": "Three", "input": 0}))
+		assertNoError(t, conn.WriteJSON(map[string]any{"id": 2, "service": "TestService1", "method": "Error", "input": "an error"}))
+
+		type result struct {
+			ID      uint64          `json:"id"`
+			Output  json.RawMessage `json:"output"`
+			Status  int             `json:"status"`
+			Message string          `json:"message"`
+		}
+
+		results := map[uint64]result{}
+		for i := 0; i < 2; i++ {
+			var resp result
+			assertNoError(t, conn.ReadJSON(&resp))
+			results[resp.ID] = resp
+		}
+
+		assertEqual(t, "3", string(results[1].Output))
+		assertEqual(t, http.StatusInternalServerError, results[2].Status)
+		assertEqual(t, "an error", results[2].Message)
+	})
+
+	t.Run("subscription", func(t *testing.T) {
+		rpc := newTestServer(WithWebSocket())
+
+		rpc.Register(&TestServiceStream{})
+
+		conn := dial(t, rpc)
+
+		assertNoError(t, conn.WriteJSON(map[string]any{"id": 1, "service": "TestServiceStream", "method": "Count", "input": 2}))
+
+		var outputs []string
+		for {
+			var resp wsResponse
+			assertNoError(t, conn.ReadJSON(&resp))
+			assertEqual(t, uint64(1), resp.ID)
+
+			if resp.End {
+				break
+			}
+
+			outputs = append(outputs, string(resp.Output))
+		}
+
+		assertEqual(t, "0,1", strings.Join(outputs, ","))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		rpc := newTestServer(WithWebSocket())
+
+		service := &TestServiceBlock{canceled: make(chan struct{})}
+		rpc.Register(service)
+
+		conn := dial(t, rpc)
+
+		assertNoError(t, conn.WriteJSON(map[string]any{"id": 1, "service": "TestServiceBlock", "method": "Block"}))
+		assertNoError(t, conn.WriteJSON(map[string]any{"id": 1, "cancel": true}))
+
+		<-service.canceled
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		rpc := newTestServer(WithWebSocket())
+
+		service := &TestServiceBlock{canceled: make(chan struct{})}
+		rpc.Register(service)
+
+		conn := dial(t, rpc)
+
+		assertNoError(t, conn.WriteJSON(map[string]any{"id": 1, "service": "TestServiceBlock", "method": "Block"}))
+		conn.Close()
+
+		<-service.canceled
+	})
+
+	t.Run("malformed message", func(t *testing.T) {
+		for _, msg := range []string{"malformed", `{"service": "TestService1", "method": "Three", "input": 0}`} {
+			rpc := newTestServer(WithWebSocket())
+
+			rpc.Register(&TestService1{})
+
+			conn := dial(t, rpc)
+
+			assertNoError(t, conn.WriteMessage(websocket.TextMessage, []byte(msg)))
+
+			var resp wsResponse
+			err := conn.ReadJSON(&resp)
+
+			var closeErr *websocket.CloseError
+			assertEqual(t, true, errors.As(err, &closeErr))
+			assertEqual(t, websocket.CloseInvalidFramePayloadData, closeErr.Code)
+			assertEqual(t, true, closeErr.Text != "")
+		}
+	})
+
+	t.Run("too many calls", func(t *testing.T) {
+		rpc := newTestServer(WithWebSocket())
+
+		rpc.Register(&TestServiceSlow{})
+
+		conn := dial(t, rpc)
+
+		for i := 1; i <= wsMaxCalls+1; i++ {
+			assertNoError(t, conn.WriteJSON(map[string]any{"id": i, "service": "TestServiceSlow", "method": "Wait", "input": 60000}))
+		}
+
+		var resp struct {
+			ID uint64 `json:"id"`
+			errorResponse
+		}
+		assertNoError(t, conn.ReadJSON(&resp))
+		assertEqual(t, uint64(wsMaxCalls+1), resp.ID)
+		assertEqual(t, http.StatusServiceUnavailable, resp.Status)
+		assertEqual(t, CodeUnavailable, resp.Code)
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		rpc := newTestServer()
+
+		rpc.Register(&TestService1{})
+
+		server := httptest.NewServer(rpc)
+		t.Cleanup(server.Close)
+
+		_, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+
+		assertErrorIs(t, websocket.ErrBadHandshake, err)
 	})
 }
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/olahol/tsreflect"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 // datePrefix string used to prefix turborpc.Date objects when marshaled to JSON.
@@ -30,6 +32,71 @@ func (d Date) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf(`"%s(%s)"`, datePrefix, bs)), err
 }
 
+// UnmarshalJSON decodes a date marshaled by MarshalJSON, or an RFC 3339
+// string like the ones JavaScript's JSON.stringify produces for a Date. The
+// server needs it to decode the Date inputs of methods sent by any client.
+func (d *Date) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+
+	var s string
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(s, datePrefix+"(") && strings.HasSuffix(s, ")") {
+		s = s[len(datePrefix)+1 : len(s)-1]
+	}
+
+	var t time.Time
+
+	if err := t.UnmarshalText([]byte(s)); err != nil {
+		return err
+	}
+
+	*d = Date(t)
+
+	return nil
+}
+
+// EncodeMsgpack encodes the date as a MessagePack timestamp.
+func (d Date) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.EncodeTime(time.Time(d))
+}
+
+// DecodeMsgpack decodes a date encoded as a MessagePack timestamp.
+func (d *Date) DecodeMsgpack(dec *msgpack.Decoder) error {
+	t, err := dec.DecodeTime()
+
+	if err != nil {
+		return err
+	}
+
+	*d = Date(t)
+
+	return nil
+}
+
+// MarshalCBOR encodes the date as a CBOR date/time string.
+func (d Date) MarshalCBOR() ([]byte, error) {
+	return cborEncMode.Marshal(time.Time(d))
+}
+
+// UnmarshalCBOR decodes a date encoded as a CBOR date/time.
+func (d *Date) UnmarshalCBOR(b []byte) error {
+	var t time.Time
+
+	if err := cborDecMode.Unmarshal(b, &t); err != nil {
+		return err
+	}
+
+	*d = Date(t)
+
+	return nil
+}
+
 func (Date) TypeScriptType(g *tsreflect.Generator, optional bool) string {
 	return "Date"
 }
@@ -52,6 +119,22 @@ func (nns NonNullSlice[T]) MarshalJSON() ([]byte, error) {
 	return json.Marshal([]T(nns))
 }
 
+func (nns NonNullSlice[T]) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if nns == nil {
+		return enc.Encode([]T{})
+	}
+
+	return enc.Encode([]T(nns))
+}
+
+func (nns NonNullSlice[T]) MarshalCBOR() ([]byte, error) {
+	if nns == nil {
+		return cborEncMode.Marshal([]T{})
+	}
+
+	return cborEncMode.Marshal([]T(nns))
+}
+
 func (nns NonNullSlice[T]) TypeScriptType(g *tsreflect.Generator, optional bool) string {
 	if reflect.TypeOf([]T(nns)) == typeOfByteSlice {
 		return "string"
@@ -75,6 +158,22 @@ func (nnm NonNullMap[K, V]) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[K]V(nnm))
 }
 
+func (nnm NonNullMap[K, V]) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if nnm == nil {
+		return enc.Encode(map[K]V{})
+	}
+
+	return enc.Encode(map[K]V(nnm))
+}
+
+func (nnm NonNullMap[K, V]) MarshalCBOR() ([]byte, error) {
+	if nnm == nil {
+		return cborEncMode.Marshal(map[K]V{})
+	}
+
+	return cborEncMode.Marshal(map[K]V(nnm))
+}
+
 func (nnm NonNullMap[K, V]) TypeScriptType(g *tsreflect.Generator, optional bool) string {
 	typ := reflect.TypeOf(nnm)
 	return fmt.Sprintf("{ [key: %s]: %s }", g.TypeOf(typ.Key()), g.TypeOf(typ.Elem()))
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/olahol/tsreflect"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func TestDate(t *testing.T) {
@@ -18,6 +20,25 @@ func TestDate(t *testing.T) {
 		assertEqual(t, fmt.Sprintf(`"%s(%s)"`, datePrefix, "0001-01-01T00:00:00Z"), string(b))
 	})
 
+	t.Run("unmarshal", func(t *testing.T) {
+		want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		for _, s := range []string{
+			fmt.Sprintf(`"%s(%s)"`, datePrefix, "2024-01-02T03:04:05Z"),
+			`"2024-01-02T03:04:05.000Z"`,
+		} {
+			var x Date
+
+			assertNoError(t, json.Unmarshal([]byte(s), &x))
+			assertEqual(t, true, want.Equal(time.Time(x)))
+		}
+
+		var x Date
+
+		assertEqual(t, true, json.Unmarshal([]byte(`"tomorrow"`), &x) != nil)
+		assertEqual(t, true, json.Unmarshal([]byte(`1`), &x) != nil)
+	})
+
 	t.Run("type", func(t *testing.T) {
 		var x Date
 
@@ -69,6 +90,34 @@ func TestNonNullSlice(t *testing.T) {
 
 	})
 
+	t.Run("msgpack", func(t *testing.T) {
+		var x NonNullSlice[string]
+
+		for _, tt := range []struct {
+			v    any
+			want any
+		}{
+			{x, []string{}},
+			{&x, []string{}},
+			{outputResponse{Output: x}, outputResponse{Output: []string{}}},
+			{[]any{map[string]any{"x": x}}, []any{map[string]any{"x": []string{}}}},
+			{NonNullSlice[string]{"test"}, []string{"test"}},
+			{struct{ X *TestShipment }{&TestShipment{}}, struct{ X *TestShipment }{&TestShipment{Tags: NonNullSlice[string]{}}}},
+			{nil, nil},
+		} {
+			b, err := MessagePackCodec.Marshal(tt.v)
+			assertNoError(t, err)
+
+			want, _ := MessagePackCodec.Marshal(tt.want)
+			assertEqual(t, string(want), string(b))
+		}
+
+		// The codec does not change how msgpack encodes the types elsewhere.
+		b, err := msgpack.Marshal(x)
+		assertNoError(t, err)
+		assertEqual(t, "\xc0", string(b))
+	})
+
 	t.Run("byte type", func(t *testing.T) {
 		var x NonNullSlice[byte]
 
@@ -97,6 +146,26 @@ func TestNonNullMap(t *testing.T) {
 		assertEqual(t, `{"10":11}`, string(b))
 	})
 
+	t.Run("msgpack", func(t *testing.T) {
+		var x NonNullMap[string, int]
+
+		for _, tt := range []struct {
+			v    any
+			want any
+		}{
+			{x, map[string]int{}},
+			{struct{ X NonNullMap[string, int] }{}, struct{ X map[string]int }{X: map[string]int{}}},
+			{outputResponse{Output: x}, outputResponse{Output: map[string]int{}}},
+			{NonNullMap[string, int]{"a": 1}, map[string]int{"a": 1}},
+		} {
+			b, err := MessagePackCodec.Marshal(tt.v)
+			assertNoError(t, err)
+
+			want, _ := MessagePackCodec.Marshal(tt.want)
+			assertEqual(t, string(want), string(b))
+		}
+	})
+
 	t.Run("type", func(t *testing.T) {
 		var x NonNullMap[int, int]
 
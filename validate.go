@@ -0,0 +1,129 @@
+package turborpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// CodeInvalidInput is the error code of inputs that fail validation, its
+// details are a ValidationError.
+const CodeInvalidInput = "invalid_input"
+
+// A Validator is a method input that validates itself. Inputs implementing
+// Validator, with either a value or pointer receiver, are validated after they
+// are decoded and before the method is called.
+type Validator interface {
+	Validate() error
+}
+
+// A FieldError describes an invalid field of a method input. Field is the
+// path of the field as it appears in the JSON input, e.g. "items.0.name".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// A ValidationError is returned by Validate, or a validator, when more than
+// one field of an input is invalid. It is sent to the client as the details
+// of an error with code CodeInvalidInput and status code 422.
+type ValidationError struct {
+	Fields NonNullSlice[FieldError] `json:"fields"`
+}
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// WithValidator sets a function that validates every decoded method input,
+// for example a struct tag based validator. It is called after the input's
+// own Validate method and its errors are answered the same way.
+func WithValidator(validate func(input any) error) ServerOption {
+	return func(r *Server) {
+		r.validator = validate
+	}
+}
+
+// validateInterceptor returns the innermost interceptor of every method
+// call, it validates the input right before the method is called.
+func validateInterceptor(validate func(input any) error) Interceptor {
+	return func(ctx context.Context, info CallInfo, input any, next Invoker) (any, error) {
+		if err := validateInput(input, validate); err != nil {
+			return nil, err
+		}
+
+		return next(ctx, input)
+	}
+}
+
+func validateInput(input any, validate func(input any) error) error {
+	if input == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(input)
+
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil
+	}
+
+	validator, ok := input.(Validator)
+
+	if !ok {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		validator, ok = ptr.Interface().(Validator)
+	}
+
+	if ok {
+		if err := validator.Validate(); err != nil {
+			return validationError(err)
+		}
+	}
+
+	if validate != nil {
+		if err := validate(input); err != nil {
+			return validationError(err)
+		}
+	}
+
+	return nil
+}
+
+// validationError turns an error from validation into an RPCError with
+// code CodeInvalidInput, unless it already is an RPCError.
+func validationError(err error) error {
+	var rpcErr *RPCError
+
+	if errors.As(err, &rpcErr) {
+		return err
+	}
+
+	var details ValidationError
+	var fieldErr FieldError
+
+	switch {
+	case errors.As(err, &details):
+	case errors.As(err, &fieldErr):
+		details.Fields = NonNullSlice[FieldError]{fieldErr}
+	}
+
+	return &RPCError{
+		Code:    CodeInvalidInput,
+		Status:  http.StatusUnprocessableEntity,
+		Message: err.Error(),
+		Details: details,
+	}
+}
@@ -0,0 +1,269 @@
+package turborpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsMessageOverhead is the number of bytes allowed in a message besides the
+// input of the call.
+const wsMessageOverhead = 4096
+
+// wsMaxInputBytes is the maximum size of the input of a call to a method
+// without a limit on its request size over a WebSocket, as messages are read
+// into memory as a whole.
+const wsMaxInputBytes = 1 << 20
+
+// wsMaxCalls is the maximum number of calls in progress on a connection.
+const wsMaxCalls = 100
+
+// wsMaxCloseReason is the maximum length of the reason of a close message.
+const wsMaxCloseReason = 123
+
+var (
+	errDecodingMessage = errors.New("decoding message")
+	errMissingID       = errors.New("call has no id")
+	errDuplicateCall   = errors.New("call already in progress with id")
+)
+
+// WithWebSocket makes the server accept WebSocket connections on GET requests
+// that ask to be upgraded, see serveWebSocket.
+func WithWebSocket() ServerOption {
+	return func(r *Server) {
+		r.webSocket = &websocket.Upgrader{}
+	}
+}
+
+// wsRequest is a message sent by a WebSocket client. A request either calls a
+// method or cancels an ongoing call with the same id.
+type wsRequest struct {
+	ID      uint64          `json:"id"`
+	Service string          `json:"service"`
+	Method  string          `json:"method"`
+	Input   json.RawMessage `json:"input"`
+	Timeout int64           `json:"timeoutMs"`
+	Cancel  bool            `json:"cancel"`
+}
+
+// wsResponse is a message sent to a WebSocket client.
+type wsResponse struct {
+	ID      uint64          `json:"id"`
+	Version string          `json:"version,omitempty"`
+	Output  json.RawMessage `json:"output,omitempty"`
+	End     bool            `json:"end,omitempty"`
+	*errorResponse
+}
+
+// wsConn is a WebSocket connection that is safe for concurrent writes.
+type wsConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *wsConn) write(resp wsResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.WriteJSON(resp)
+}
+
+// close sends a close message with a status code and an error as the reason.
+func (c *wsConn) close(code int, err error) {
+	reason := err.Error()
+
+	if len(reason) > wsMaxCloseReason {
+		reason = strings.ToValidUTF8(reason[:wsMaxCloseReason], "")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
+
+// serveWebSocket answers RPC calls over a WebSocket connection. The first
+// message sent by the server is {"id": 0, "version": ...} with the server
+// version. After that the client sends calls on the form
+//
+//	{"id": 1, "service": "Service", "method": "Method", "input": ...}
+//
+// which are executed concurrently. A call may have a "timeoutMs" field with
+// the number of milliseconds the client waits for it, see WithTimeout. The
+// server answers a call with a message with the same id that is either
+// {"id": 1, "output": ...} or {"id": 1, "status": ..., "message": ...}. Calls
+// to streaming methods are subscriptions, the server sends an output message
+// for every value and ends the subscription with either {"id": 1, "end": true}
+// or an error message.
+//
+// A client cancels a call or subscription by sending {"id": 1, "cancel": true}.
+// All calls are canceled when the connection is closed.
+//
+// A message that is not a call, or a call without an id, cannot be answered so
+// the server closes the connection with status code 1007 and the error as the
+// reason. At most wsMaxCalls calls may be in progress on a connection, further
+// calls are answered with ErrOverloaded and status code 503.
+//
+// Messages are limited to the largest input a method accepts, where methods
+// without a limit accept wsMaxInputBytes, and the connection is closed with
+// status code 1009 when a larger message is sent.
+func (rpc *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := rpc.webSocket.Upgrade(w, r, http.Header{"X-Server-Version": {rpc.version}})
+
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	// Leave room for the rest of the message around the input.
+	conn.SetReadLimit(rpc.maxInputBytes(wsMaxInputBytes) + wsMessageOverhead)
+
+	ctx, cancel := context.WithCancel(withRequest(r.Context(), r))
+	defer cancel()
+
+	c := &wsConn{conn: conn}
+
+	if err := c.write(wsResponse{Version: rpc.version}); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+	calls := make(map[uint64]context.CancelFunc)
+
+	var wg sync.WaitGroup
+	for {
+		_, buf, err := conn.ReadMessage()
+
+		if err != nil {
+			break
+		}
+
+		var req wsRequest
+
+		if err := json.Unmarshal(buf, &req); err != nil {
+			c.close(websocket.CloseInvalidFramePayloadData, fmt.Errorf("%w: %w", errDecodingMessage, err))
+			break
+		}
+
+		if req.ID == 0 {
+			c.close(websocket.CloseInvalidFramePayloadData, errMissingID)
+			break
+		}
+
+		mu.Lock()
+		cancelCall, inProgress := calls[req.ID]
+		n := len(calls)
+		mu.Unlock()
+
+		if req.Cancel {
+			if inProgress {
+				cancelCall()
+			}
+
+			continue
+		}
+
+		if inProgress {
+			resp := newErrorResponse(http.StatusBadRequest, fmt.Errorf("%w %d", errDuplicateCall, req.ID))
+			c.write(wsResponse{ID: req.ID, errorResponse: &resp})
+			continue
+		}
+
+		if n >= wsMaxCalls {
+			resp := newErrorResponse(http.StatusServiceUnavailable, fmt.Errorf("%w on connection, at most %d", ErrOverloaded, wsMaxCalls))
+			c.write(wsResponse{ID: req.ID, errorResponse: &resp})
+			continue
+		}
+
+		callCtx, cancelCall := context.WithCancel(ctx)
+		callCtx, cancelTimeout := clientTimeout(callCtx, req.Timeout)
+
+		mu.Lock()
+		calls[req.ID] = cancelCall
+		mu.Unlock()
+
+		wg.Add(1)
+
+		go func(req wsRequest) {
+			defer wg.Done()
+
+			rpc.wsCall(callCtx, c, req)
+
+			mu.Lock()
+			delete(calls, req.ID)
+			mu.Unlock()
+
+			cancelTimeout()
+			cancelCall()
+		}(req)
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+// wsCall answers a single call made over a WebSocket connection.
+func (rpc *Server) wsCall(ctx context.Context, c *wsConn, req wsRequest) {
+	s, m, err := rpc.lookup(req.Service, req.Method)
+
+	switch {
+	case err != nil:
+	case m.stream != nil:
+		var mu sync.Mutex
+		closed := false
+
+		err = m.invokeStream(ctx, CallInfo{Service: s.name, Method: m.name, Stream: true}, s.interceptors, m.bytesInput(req.Input), JSONCodec, func(v any) error {
+			buf, err := json.Marshal(v)
+
+			if err != nil {
+				return fmt.Errorf("%w: %w", errEncodingOutput, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if closed {
+				return errStreamClosed
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return c.write(wsResponse{ID: req.ID, Output: buf})
+		})
+
+		mu.Lock()
+		closed = true
+		mu.Unlock()
+
+		if err == nil {
+			c.write(wsResponse{ID: req.ID, End: true})
+			return
+		}
+	default:
+		var output any
+		output, err = rpc.call(ctx, s, m, m.bytesInput(req.Input), JSONCodec)
+
+		var buf []byte
+		if err == nil {
+			buf, err = encodeOutput(JSONCodec, CallInfo{Service: s.name, Method: m.name}, output)
+		}
+
+		if err == nil {
+			c.write(wsResponse{ID: req.ID, Output: buf})
+			return
+		}
+	}
+
+	resp := newErrorResponse(rpc.errorStatus(err))
+	c.write(wsResponse{ID: req.ID, errorResponse: &resp})
+}